@@ -5,7 +5,37 @@ export AWS_REGION="eu-west-1"
 
 export INFRA_AWS_S3_BUCKET="aws-s3-bucket"
 export PERSONAL_ACCESS_TOKEN="****"
+# or, instead of the personal access token, a GitHub App installation
+# export GITHUB_APP_ID="123456"
+# export GITHUB_APP_INSTALLATION_ID="1234567"
+# export GITHUB_APP_PRIVATE_KEY="$(cat app.private-key.pem)"
+# generated with `infra keygen`
+export ARTIFACT_SIGNING_KEY="****"
+export ARTIFACT_VERIFY_KEY="****"
 
 
 # user env
 export REPOSITORY="owner/repo"
+export PROMOTION_ORDER="dev,staging,prod"
+
+# `infra build` / `infra deploy` when run locally (GITHUB_ACTIONS not "true"), on GitHub Actions
+# the event payload is read from the repository_dispatch event at GITHUB_EVENT_PATH
+# export GITHUB_REPOSITORY="owner/repo"
+# export SERVICE="demo-service"
+# export ENV="dev"
+# export CHECKSUM="****"
+# export COMMIT_SHA="****"
+
+# artifact store ("s3" uses INFRA_AWS_S3_BUCKET, "local" a directory)
+export INFRA_ARTIFACT_STORE="s3"
+# export INFRA_ARTIFACT_STORE="local"
+# export INFRA_ARTIFACT_STORE_DIR=".infra-store"
+
+# github enterprise server, api.github.com if unset
+# export GITHUB_BASE_URL="https://github.example.com/api/v3/"
+# export GITHUB_UPLOAD_URL="https://github.example.com/api/uploads/"
+
+# github api retries
+# export GITHUB_RETRY_MAX_ATTEMPTS="5"
+# export GITHUB_RETRY_BASE_DELAY="1s"
+# export GITHUB_RETRY_MAX_DELAY="1m"
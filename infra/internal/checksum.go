@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// goPackage is the subset of the `go list -json` output needed to compute code checksums.
+type goPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *struct {
+		Path  string
+		Main  bool
+		GoMod string
+	}
+}
+
+// ComputeCodeChecksum hashes everything that ends up in the service dist zip:
+//   - the service directory (excluding compiled binaries)
+//   - every package of the service's go module imported by the service packages (e.g. backend/common)
+//   - the module go.mod and go.sum files
+//   - the go toolchain version
+func (bu *BuildUtils) ComputeCodeChecksum() (string, error) {
+	fPaths, err := bu.codeFiles()
+	if err != nil {
+		return "", err
+	}
+
+	goVersion, err := goToolchainVersion()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha1.New()
+	_, err = hash.Write([]byte(goVersion))
+	if err != nil {
+		return "", err
+	}
+
+	for _, fPath := range fPaths {
+		err := func() error {
+			fReader, err := os.Open(fPath)
+			if err != nil {
+				return err
+			}
+			defer fReader.Close()
+
+			_, err = hash.Write([]byte(filepath.ToSlash(fPath)))
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(hash, fReader)
+			return err
+		}()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// codeFiles returns the sorted paths, relative to the working directory, of the files the checksum covers.
+func (bu *BuildUtils) codeFiles() ([]string, error) {
+	fPathSet := map[string]bool{}
+
+	err := filepath.Walk(bu.service, func(fPath string, fInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fInfo.IsDir() {
+			return nil
+		}
+
+		isBin, err := filepath.Match(bu.binariesPattern(), fPath)
+		if err != nil {
+			return err
+		}
+		if isBin {
+			return nil
+		}
+
+		fPathSet[fPath] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := goListDeps(fmt.Sprintf(".%s%s%s...", string(os.PathSeparator), bu.service, string(os.PathSeparator)))
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	addFile := func(fPath string) error {
+		rel, err := filepath.Rel(wd, fPath)
+		if err != nil {
+			return err
+		}
+		fPathSet[rel] = true
+		return nil
+	}
+
+	for _, pkg := range pkgs {
+		if pkg.Standard || pkg.Module == nil || !pkg.Module.Main {
+			continue
+		}
+
+		fInfos, err := ioutil.ReadDir(pkg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, fInfo := range fInfos {
+			if fInfo.IsDir() {
+				continue
+			}
+			err := addFile(filepath.Join(pkg.Dir, fInfo.Name()))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if pkg.Module.GoMod != "" {
+			err := addFile(pkg.Module.GoMod)
+			if err != nil {
+				return nil, err
+			}
+
+			goSum := filepath.Join(filepath.Dir(pkg.Module.GoMod), "go.sum")
+			if _, err := os.Stat(goSum); err == nil {
+				err := addFile(goSum)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	fPaths := make([]string, 0, len(fPathSet))
+	for fPath := range fPathSet {
+		fPaths = append(fPaths, fPath)
+	}
+	sort.Strings(fPaths)
+	return fPaths, nil
+}
+
+// goListDeps resolves the import graph of the given package pattern.
+func goListDeps(pattern string) ([]goPackage, error) {
+	cmd := exec.Command("go", "list", "-deps", "-json", pattern)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("go list failed: %s", strings.TrimSpace(stderr.String())))
+	}
+
+	var pkgs []goPackage
+	decoder := json.NewDecoder(&stdout)
+	for {
+		pkg := goPackage{}
+		err := decoder.Decode(&pkg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode go list output")
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func goToolchainVersion() (string, error) {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get go version")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,48 @@
+package internal_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestComputeCodeChecksum(t *testing.T) {
+	dir := tempDir(t)
+
+	writeFile(t, filepath.Join(dir, "go.mod"), "module backend\n\ngo 1.14\n")
+	writeFile(t, filepath.Join(dir, "common", "common.go"), "package common\n\nconst BestFruit = \"orange\"\n")
+	writeFile(t, filepath.Join(dir, "unused", "unused.go"), "package unused\n")
+	writeFile(t, filepath.Join(dir, "demo-service", "serverless.yml"), "service: demo\n")
+	writeFile(t, filepath.Join(dir, "demo-service", "echo", "main.go"),
+		"package main\n\nimport \"backend/common\"\n\nfunc main() { println(common.BestFruit) }\n")
+
+	chdir(t, dir)
+
+	bu, err := internal.NewBuildUtils(nil, "demo-service")
+	require.NoError(t, err)
+
+	checksum := func() string {
+		c, err := bu.ComputeCodeChecksum()
+		require.NoError(t, err)
+		return c
+	}
+
+	initial := checksum()
+	require.Equal(t, initial, checksum())
+
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "binary")
+	require.Equal(t, initial, checksum(), "binaries are not part of the checksum")
+
+	writeFile(t, filepath.Join(dir, "unused", "unused.go"), "package unused\n\n// changed\n")
+	require.Equal(t, initial, checksum(), "packages not imported by the service are not part of the checksum")
+
+	writeFile(t, filepath.Join(dir, "common", "common.go"), "package common\n\nconst BestFruit = \"lemon\"\n")
+	afterCommon := checksum()
+	require.NotEqual(t, initial, afterCommon, "imported packages are part of the checksum")
+
+	writeFile(t, filepath.Join(dir, "go.sum"), "\n")
+	require.NotEqual(t, afterCommon, checksum(), "module files are part of the checksum")
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"infra/internal"
+)
+
+type buildCmd struct{}
+
+func (*buildCmd) Name() string { return "build" }
+
+func (*buildCmd) Synopsis() string {
+	return "upload the service dist zip and trigger its deploy to dev (backend-build workflow)"
+}
+
+func (*buildCmd) SetFlags(fs *flag.FlagSet) {}
+
+func (*buildCmd) Run(ctx context.Context) error {
+	env, err := loadCIEnv()
+	if err != nil {
+		return err
+	}
+
+	eventPayload, err := internal.LoadBackendBuildEventPayload(env.GitHubEnv)
+	if err != nil {
+		return err
+	}
+
+	githubClient, err := env.githubClient(env.GitHubRepository)
+	if err != nil {
+		return err
+	}
+
+	service := eventPayload.Service
+	reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, service, internal.CommitStatusPending, "building")
+	err = build(ctx, env, githubClient, eventPayload)
+	if err != nil {
+		if errors.Is(err, internal.ErrLastChecksumRace) {
+			reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, service, internal.CommitStatusError, "superseded by a newer build")
+		} else {
+			reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, service, internal.CommitStatusFailure, "build failed")
+		}
+		return err
+	}
+
+	log.Print("done")
+	return nil
+}
+
+// build uploads the dist zip, updates the last checksum and triggers the deploy to dev.
+func build(ctx context.Context, env *CIEnv, githubClient *internal.GitHubClient, eventPayload *internal.BackendBuildEventPayload) error {
+	bu, err := env.buildUtils(eventPayload.Service)
+	if err != nil {
+		return err
+	}
+
+	// read before building, the pointer is only updated if no newer build updated it meanwhile
+	lastChecksumPointer, err := bu.GetLastCodeChecksumPointer()
+	if err != nil {
+		return err
+	}
+
+	commitTime, err := internal.CommitTime(eventPayload.CommitSHA)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := bu.ComputeCodeChecksum()
+	if err != nil {
+		return err
+	}
+	log.Print(fmt.Sprintf("code checksum: %s", checksum))
+
+	zData, err := bu.GenerateDistZip()
+	if err != nil {
+		return err
+	}
+	log.Print(fmt.Sprintf("dist zip generated, sha256: %s", internal.DistZipDigest(zData)))
+
+	manifest, err := bu.NewArtifactManifest(zData, checksum, eventPayload.CommitSHA, env.GitHubRunID)
+	if err != nil {
+		return err
+	}
+
+	signingKey, err := env.signingKey()
+	if err != nil {
+		return err
+	}
+	if signingKey == nil {
+		log.Print("no signing key configured, the artifact will not be signed")
+	}
+
+	log.Print("uploading dist zip")
+	err = bu.UploadDistZip(manifest, zData, signingKey)
+	if err != nil {
+		return err
+	}
+	log.Print("dist zip and manifest uploaded")
+
+	log.Print("updating last checksum")
+	err = bu.CompareAndSwapLastCodeChecksum(lastChecksumPointer, internal.ChecksumPointer{
+		Checksum:   checksum,
+		CommitSHA:  eventPayload.CommitSHA,
+		CommitTime: commitTime,
+	})
+	if err != nil {
+		if errors.Is(err, internal.ErrLastChecksumRace) {
+			return errors.Wrap(err, "build lost the race to a newer build, not triggering deploy")
+		}
+		return err
+	}
+	log.Print("last checksum updated")
+	reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, eventPayload.Service, internal.CommitStatusPending, fmt.Sprintf("built %s", checksum))
+
+	log.Print("triggering deploy event")
+	deployEnv := "dev" // deploy on dev automatically
+	deployEventPayload := internal.BackendDeployEventPayload{
+		Env:       deployEnv,
+		Service:   eventPayload.Service,
+		Checksum:  checksum,
+		CommitSHA: eventPayload.CommitSHA,
+	}
+	err = githubClient.DispatchEvent(ctx, &deployEventPayload)
+	if err != nil {
+		return err
+	}
+	log.Print("deploy event triggered")
+	return nil
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Exit codes of the `infra` binary.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+// Command is an `infra` subcommand.
+type Command interface {
+	// Name is the subcommand name, e.g. "hash".
+	Name() string
+	// Synopsis is the one line description shown by `infra --help`.
+	Synopsis() string
+	// SetFlags registers the command flags, values are available once Run is called.
+	SetFlags(fs *flag.FlagSet)
+	Run(ctx context.Context) error
+}
+
+var commands = map[string]Command{}
+
+// Register makes cmd available as `infra <cmd.Name()>`.
+func Register(cmd Command) {
+	if _, ok := commands[cmd.Name()]; ok {
+		panic(fmt.Sprintf("command %s registered twice", cmd.Name()))
+	}
+	commands[cmd.Name()] = cmd
+}
+
+func init() {
+	Register(&hashCmd{})
+	Register(&buildCmd{})
+	Register(&deployCmd{})
+	Register(&dispatchDeployCmd{})
+	Register(&rollbackCmd{})
+	Register(&promoteCmd{})
+	Register(&statusCmd{})
+	Register(&planCmd{})
+	Register(&verifyCmd{})
+	Register(&keygenCmd{})
+	Register(&gcCmd{})
+	Register(&unlockCmd{})
+}
+
+// usageError is returned by commands when they are invoked incorrectly, e.g. a required flag is missing.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func newUsageError(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, "usage: infra <command> [flags]\n\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-16s %s\n", name, commands[name].Synopsis())
+	}
+
+	fmt.Fprint(w, "\nrun `infra <command> --help` for the command flags.\n")
+	fmt.Fprintf(w, "\nexit codes: %d ok, %d failure, %d usage error\n", ExitOK, ExitFailure, ExitUsage)
+}
+
+// signalContext is cancelled on SIGINT/SIGTERM, e.g. when a GitHub Actions job is cancelled.
+func signalContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Print(fmt.Sprintf("received %s, cancelling", sig))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// Run runs the subcommand named by args[0] and returns the process exit code.
+func Run(args []string) int {
+	if len(args) < 1 {
+		usage(os.Stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage(os.Stdout)
+		return ExitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		usage(os.Stderr)
+		return ExitUsage
+	}
+
+	fs := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: infra %s [flags]\n\n%s\n\nflags:\n", cmd.Name(), cmd.Synopsis())
+		fs.PrintDefaults()
+	}
+	cmd.SetFlags(fs)
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return ExitUsage
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = cmd.Run(ctx)
+	if err != nil {
+		var uErr *usageError
+		if errors.As(err, &uErr) {
+			fmt.Fprintf(fs.Output(), "%s\n", uErr)
+			fs.Usage()
+			return ExitUsage
+		}
+		log.Print(fmt.Sprintf("%s failed: %s", cmd.Name(), err))
+		return ExitFailure
+	}
+	return ExitOK
+}
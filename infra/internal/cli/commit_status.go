@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"infra/internal"
+)
+
+// reportCommitStatus posts the status of the service on the commit.
+// Reporting is best effort, failures are logged and never fail the command.
+func reportCommitStatus(ctx context.Context, client *internal.GitHubClient, env *CIEnv, commitSHA string, service string, state string, description string) {
+	if commitSHA == "" {
+		log.Print(fmt.Sprintf("[%s] no commit sha, not reporting commit status %q", service, description))
+		return
+	}
+	err := client.CreateCommitStatus(ctx, commitSHA, service, internal.CommitStatus{
+		State:       state,
+		Description: description,
+		TargetURL:   env.RunURL(),
+	})
+	if err != nil {
+		log.Print(fmt.Sprintf("[%s] failed to report commit status %q: %s", service, description, err))
+	}
+}
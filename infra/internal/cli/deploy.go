@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"infra/internal"
+)
+
+// deployLockMargin is added to the deploy timeout to get the deploy lock ttl.
+const deployLockMargin = 5 * time.Minute
+
+type deployCmd struct {
+	timeout  time.Duration
+	lockWait time.Duration
+	plan     bool
+}
+
+func (*deployCmd) Name() string { return "deploy" }
+
+func (*deployCmd) Synopsis() string {
+	return "deploy a service dist zip with serverless and record the deployment (backend-deploy workflow)"
+}
+
+func (c *deployCmd) SetFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.timeout, "timeout", 30*time.Minute, "serverless deploy timeout")
+	fs.DurationVar(&c.lockWait, "lock-wait", 0, "how long to wait for the deploy lock held by another deploy, fail fast if 0")
+	fs.BoolVar(&c.plan, "plan", false, "only show the CloudFormation changes the deploy would make")
+}
+
+func (c *deployCmd) Run(ctx context.Context) error {
+	env, err := loadCIEnv()
+	if err != nil {
+		return err
+	}
+
+	eventPayload, err := internal.LoadBackendDeployEventPayload(env.GitHubEnv)
+	if err != nil {
+		return err
+	}
+
+	bu, err := env.buildUtils(eventPayload.Service)
+	if err != nil {
+		return err
+	}
+
+	artifact, err := bu.DownloadDistZip(eventPayload.Checksum, ".")
+	if err != nil {
+		return err
+	}
+
+	if c.plan {
+		planCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+		return plan(planCtx, bu, eventPayload.Env, artifact.Path)
+	}
+
+	verifyKey, err := env.verifyKey()
+	if err != nil {
+		return err
+	}
+
+	githubClient, err := env.githubClient(env.GitHubRepository)
+	if err != nil {
+		return err
+	}
+	if eventPayload.CommitSHA == "" {
+		eventPayload.CommitSHA = artifact.Manifest.CommitSHA
+	}
+	deployment := startGitHubDeployment(ctx, githubClient, eventPayload, env.RunURL())
+	reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, eventPayload.Service, internal.CommitStatusPending, fmt.Sprintf("deploying to %s", eventPayload.Env))
+
+	// the deploy waits at most --lock-wait for the lock, then runs at most --timeout while holding it
+	record, err := bu.Deploy(ctx, eventPayload.Env, artifact, internal.DeployOptions{
+		SigningConfig: env.SigningConfig,
+		VerifyKey:     verifyKey,
+		Lock: internal.LockOptions{
+			Owner: fmt.Sprintf("%s run %s by %s", env.GitHubWorkflow, env.GitHubRunID, env.GitHubActor),
+			TTL:   c.timeout + deployLockMargin,
+			Wait:  c.lockWait,
+		},
+		Timeout:        c.timeout,
+		CommitSHA:      eventPayload.CommitSHA,
+		Actor:          env.GitHubActor,
+		RunID:          env.GitHubRunID,
+		RolledBackFrom: eventPayload.RolledBackFrom,
+	})
+	if err != nil {
+		deployment.report(ctx, internal.GitHubDeploymentStatus{State: internal.GitHubDeploymentFailure, Description: "deploy failed"})
+		reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, eventPayload.Service, internal.CommitStatusFailure, fmt.Sprintf("deploy to %s failed", eventPayload.Env))
+		return err
+	}
+	deployment.report(ctx, internal.GitHubDeploymentStatus{
+		State:          internal.GitHubDeploymentSuccess,
+		Description:    fmt.Sprintf("deployed %s", record.Checksum),
+		EnvironmentURL: record.Endpoint,
+	})
+	reportCommitStatus(ctx, githubClient, env, eventPayload.CommitSHA, eventPayload.Service, internal.CommitStatusSuccess, fmt.Sprintf("deployed to %s", eventPayload.Env))
+	return nil
+}
+
+// githubDeployment reports a deploy to the GitHub Deployments API, as best effort as reportCommitStatus.
+type githubDeployment struct {
+	client *internal.GitHubClient
+	id     int64
+	logURL string
+}
+
+// startGitHubDeployment creates the GitHub deployment and marks it in progress, returns nil if it could not be created.
+func startGitHubDeployment(ctx context.Context, client *internal.GitHubClient, eventPayload *internal.BackendDeployEventPayload, logURL string) *githubDeployment {
+	id, err := client.CreateDeployment(ctx, eventPayload)
+	if err != nil {
+		log.Print(fmt.Sprintf("failed to create github deployment: %s", err))
+		return nil
+	}
+	log.Print(fmt.Sprintf("github deployment %d created", id))
+
+	d := &githubDeployment{client: client, id: id, logURL: logURL}
+	d.report(ctx, internal.GitHubDeploymentStatus{State: internal.GitHubDeploymentInProgress})
+	return d
+}
+
+func (d *githubDeployment) report(ctx context.Context, status internal.GitHubDeploymentStatus) {
+	if d == nil {
+		return
+	}
+	status.LogURL = d.logURL
+	err := d.client.CreateDeploymentStatus(ctx, d.id, status)
+	if err != nil {
+		log.Print(fmt.Sprintf("failed to report github deployment %d %s: %s", d.id, status.State, err))
+	}
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"infra/internal"
+)
+
+type dispatchDeployCmd struct {
+	env      string
+	service  string
+	checksum string
+}
+
+func (*dispatchDeployCmd) Name() string { return "dispatch-deploy" }
+
+func (*dispatchDeployCmd) Synopsis() string {
+	return "trigger the deploy of a service checksum (default: last built) to an environment"
+}
+
+func (c *dispatchDeployCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.env, "env", "", "environment")
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.StringVar(&c.checksum, "checksum", "", "service checksum (default: last built checksum)")
+}
+
+func (c *dispatchDeployCmd) Run(ctx context.Context) error {
+	if c.env == "" {
+		return newUsageError("`--env` not provided")
+	}
+
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	env, err := loadUserEnv()
+	if err != nil {
+		return err
+	}
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	checksum := c.checksum
+	if checksum == "" {
+		log.Print("getting last checksum")
+		checksum, err = bu.GetLastCodeChecksum()
+		if err != nil {
+			return err
+		}
+		log.Print(fmt.Sprintf("last code checksum: %s", checksum))
+	}
+
+	err = bu.ValidateDeployOrder(env.PromotionOrder, c.env, checksum)
+	if err != nil {
+		return err
+	}
+
+	log.Print("triggering deploy event")
+	githubClient, err := env.githubClient(env.Repository)
+	if err != nil {
+		return err
+	}
+	eventPayload := internal.BackendDeployEventPayload{
+		Env:      c.env,
+		Service:  c.service,
+		Checksum: checksum,
+	}
+	err = githubClient.DispatchEvent(ctx, &eventPayload)
+	if err != nil {
+		return err
+	}
+	log.Print("deploy event triggered")
+
+	log.Print("done")
+	return nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"crypto/ed25519"
+
+	"infra/internal"
+)
+
+// Env is the configuration shared by every command, loaded from env variables.
+type Env struct {
+	*internal.Secrets
+	*internal.ArtifactStoreConfig
+	*internal.SigningConfig
+	*internal.GitHubConfig
+}
+
+func loadEnv() (*Env, error) {
+	secrets, err := internal.LoadSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	storeConfig, err := internal.LoadArtifactStoreConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	signingConfig, err := internal.LoadSigningConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	githubConfig, err := internal.LoadGitHubConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Env{Secrets: secrets, ArtifactStoreConfig: storeConfig, SigningConfig: signingConfig, GitHubConfig: githubConfig}, nil
+}
+
+func (e *Env) artifactStore() (internal.ArtifactStore, error) {
+	return internal.NewArtifactStore(e.ArtifactStoreConfig, e.InfraBucket)
+}
+
+func (e *Env) buildUtils(service string) (*internal.BuildUtils, error) {
+	store, err := e.artifactStore()
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewBuildUtils(store, service)
+}
+
+// signingKey returns nil if no signing key is configured.
+func (e *Env) signingKey() (ed25519.PrivateKey, error) {
+	if e.ArtifactSigningKey == "" {
+		return nil, nil
+	}
+	return internal.ParseSigningKey(e.ArtifactSigningKey)
+}
+
+// verifyKey returns nil if no verify key is configured.
+func (e *Env) verifyKey() (ed25519.PublicKey, error) {
+	if e.ArtifactVerifyKey == "" {
+		return nil, nil
+	}
+	return internal.ParseVerifyKey(e.ArtifactVerifyKey)
+}
+
+func (e *Env) githubClient(repository string) (*internal.GitHubClient, error) {
+	return internal.NewGitHubClient(repository, e.Secrets, e.GitHubConfig)
+}
+
+// CIEnv is the configuration of the commands run by GitHub Actions workflows.
+type CIEnv struct {
+	*Env
+	*internal.GitHubEnv
+}
+
+func loadCIEnv() (*CIEnv, error) {
+	env, err := loadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	githubEnv, err := internal.LoadGitHubEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CIEnv{Env: env, GitHubEnv: githubEnv}, nil
+}
+
+// UserEnv is the configuration of the commands run by developers.
+type UserEnv struct {
+	*Env
+	*internal.UserEnv
+}
+
+func loadUserEnv() (*UserEnv, error) {
+	env, err := loadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	userEnv, err := internal.LoadUserEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserEnv{Env: env, UserEnv: userEnv}, nil
+}
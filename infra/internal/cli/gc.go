@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"infra/internal"
+)
+
+type gcCmd struct {
+	service  string
+	keepLast int
+	keepDays int
+	dryRun   bool
+}
+
+func (*gcCmd) Name() string { return "gc" }
+
+func (*gcCmd) Synopsis() string {
+	return "delete old build artifacts from the infra bucket"
+}
+
+func (c *gcCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.service, "service", "", "service id (default: every service)")
+	fs.IntVar(&c.keepLast, "keep", 10, "number of most recent builds kept per service")
+	fs.IntVar(&c.keepDays, "keep-days", 30, "builds deployed to any environment within this number of days are kept")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "only list what would be deleted")
+}
+
+func (c *gcCmd) Run(ctx context.Context) error {
+	if c.keepLast < 0 {
+		return newUsageError("invalid `--keep`: %d", c.keepLast)
+	}
+
+	if c.keepDays < 0 {
+		return newUsageError("invalid `--keep-days`: %d", c.keepDays)
+	}
+
+	env, err := loadEnv()
+	if err != nil {
+		return err
+	}
+
+	store, err := env.artifactStore()
+	if err != nil {
+		return err
+	}
+
+	policy := internal.GCPolicy{
+		KeepLast:           c.keepLast,
+		KeepDeployedWithin: time.Duration(c.keepDays) * 24 * time.Hour,
+	}
+	candidates, err := internal.GCCandidates(store, c.service, policy, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if len(candidates) < 1 {
+		log.Print("nothing to delete")
+		return nil
+	}
+
+	var total int64
+	for _, artifact := range candidates {
+		total += artifact.Size
+		log.Print(fmt.Sprintf("%s %s (built %s, %s)",
+			artifact.Service, artifact.Checksum, artifact.BuiltAt.Format(time.RFC3339), formatBytes(artifact.Size),
+		))
+	}
+
+	if c.dryRun {
+		log.Print(fmt.Sprintf("dry run: %d build(s) would be deleted, %s reclaimed", len(candidates), formatBytes(total)))
+		return nil
+	}
+
+	for _, artifact := range candidates {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := internal.DeleteArtifact(store, artifact)
+		if err != nil {
+			return err
+		}
+	}
+	log.Print(fmt.Sprintf("%d build(s) deleted, %s reclaimed", len(candidates), formatBytes(total)))
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"infra/internal"
+)
+
+type hashCmd struct {
+	commitSHA string
+	service   string
+	root      string
+}
+
+func (*hashCmd) Name() string { return "hash" }
+
+func (*hashCmd) Synopsis() string {
+	return "compute services code checksums and trigger a build for every changed service"
+}
+
+func (c *hashCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.commitSHA, "commit-sha", "", "commit sha")
+	fs.StringVar(&c.service, "service", "", "service id (default: every service found under --root)")
+	fs.StringVar(&c.root, "root", ".", "directory searched for services (directories containing a serverless.yml)")
+}
+
+func (c *hashCmd) Run(ctx context.Context) error {
+	if c.commitSHA == "" {
+		return newUsageError("`--commit-sha` not provided")
+	}
+
+	services := []string{c.service}
+	if c.service == "" {
+		var err error
+		services, err = internal.DiscoverServices(c.root)
+		if err != nil {
+			return err
+		}
+		if len(services) < 1 {
+			return errors.New(fmt.Sprintf("no services found under %s", c.root))
+		}
+	}
+	log.Print(fmt.Sprintf("services: %s", strings.Join(services, ", ")))
+
+	env, err := loadCIEnv()
+	if err != nil {
+		return err
+	}
+
+	githubClient, err := env.githubClient(env.GitHubRepository)
+	if err != nil {
+		return err
+	}
+
+	var changed, skipped, failed []string
+	for _, service := range services {
+		isChanged, err := c.hash(ctx, env, githubClient, service)
+		switch {
+		case err != nil:
+			log.Print(fmt.Sprintf("[%s] failed: %s", service, err))
+			failed = append(failed, service)
+			reportCommitStatus(ctx, githubClient, env, c.commitSHA, service, internal.CommitStatusFailure, "hash failed")
+		case isChanged:
+			changed = append(changed, service)
+			reportCommitStatus(ctx, githubClient, env, c.commitSHA, service, internal.CommitStatusPending, "building")
+		default:
+			skipped = append(skipped, service)
+			reportCommitStatus(ctx, githubClient, env, c.commitSHA, service, internal.CommitStatusSuccess, "unchanged")
+		}
+	}
+
+	log.Print("summary:")
+	log.Print(fmt.Sprintf("  changed (build triggered): %s", strings.Join(changed, ", ")))
+	log.Print(fmt.Sprintf("  skipped (unchanged): %s", strings.Join(skipped, ", ")))
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("failed: %s", strings.Join(failed, ", ")))
+	}
+	log.Print("done")
+	return nil
+}
+
+// hash triggers a build event for the service if its code checksum changed.
+// Returns whether the service changed.
+func (c *hashCmd) hash(ctx context.Context, env *CIEnv, githubClient *internal.GitHubClient, service string) (bool, error) {
+	bu, err := env.buildUtils(service)
+	if err != nil {
+		return false, err
+	}
+
+	log.Print(fmt.Sprintf("[%s] computing checksum", service))
+	checksum, err := bu.ComputeCodeChecksum()
+	if err != nil {
+		return false, err
+	}
+	log.Print(fmt.Sprintf("[%s] code checksum: %s", service, checksum))
+
+	log.Print(fmt.Sprintf("[%s] getting last checksum", service))
+	lastChecksum, err := bu.GetLastCodeChecksum()
+	if err != nil {
+		return false, err
+	}
+	log.Print(fmt.Sprintf("[%s] last code checksum: %s", service, lastChecksum))
+
+	if checksum == lastChecksum {
+		log.Print(fmt.Sprintf("[%s] nothing to do", service))
+		return false, nil
+	}
+
+	log.Print(fmt.Sprintf("[%s] new checksum! triggering build event", service))
+	eventPayload := internal.BackendBuildEventPayload{
+		CommitSHA: c.commitSHA,
+		Service:   service,
+	}
+	err = githubClient.DispatchEvent(ctx, &eventPayload)
+	if err != nil {
+		return false, err
+	}
+	log.Print(fmt.Sprintf("[%s] build event triggered", service))
+	return true, nil
+}
@@ -0,0 +1,292 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+	"infra/internal/cli"
+	"infra/internal/githubtest"
+)
+
+const fakeServerless = `#!/bin/sh
+echo "$@" >> serverless.log
+case "$1" in
+deploy)
+  sleep "${SERVERLESS_DEPLOY_SLEEP:-0}"
+  mkdir -p .serverless
+  echo '{"Resources": {}}' > .serverless/cloudformation-template-update-stack.json
+  ;;
+package)
+  mkdir -p .serverless
+  echo '{"Resources": {"EchoLambdaFunction": {}}}' > .serverless/cloudformation-template-update-stack.json
+  ;;
+info)
+  echo "Service Information"
+  echo "Stack Outputs"
+  echo "ServiceEndpoint: https://api.example.com/$4"
+  ;;
+esac
+`
+
+func writeFile(t *testing.T, fPath string, content string, perm os.FileMode) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(fPath), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(fPath, []byte(content), perm))
+}
+
+// setenv sets the env variable for the duration of the test, unsets it if value is empty.
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	if value == "" {
+		require.NoError(t, os.Unsetenv(key))
+	} else {
+		require.NoError(t, os.Setenv(key, value))
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// setupPipeline creates a backend repository with a demo-service, a fake serverless on PATH
+// and the env of the GitHub Actions workflows, talking to server and storing artifacts in a local store.
+// Returns the commit sha of the repository.
+func setupPipeline(t *testing.T, server *githubtest.Server) string {
+	dir, err := ioutil.TempDir("", "pipeline")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	backend := filepath.Join(dir, "backend")
+	writeFile(t, filepath.Join(backend, "go.mod"), "module backend\n\ngo 1.14\n", 0644)
+	writeFile(t, filepath.Join(backend, "demo-service", "serverless.yml"), "service: demo\n", 0644)
+	writeFile(t, filepath.Join(backend, "demo-service", "echo", "main.go"), "package main\n\nfunc main() {}\n", 0644)
+	writeFile(t, filepath.Join(backend, "demo-service", ".bin", "echo"), "binary", 0755)
+	git(t, backend, "init", "-q")
+	git(t, backend, "add", ".")
+	git(t, backend, "commit", "-q", "-m", "initial")
+	commitSHA := git(t, backend, "rev-parse", "HEAD")
+
+	bin := filepath.Join(dir, "bin")
+	writeFile(t, filepath.Join(bin, "serverless"), fakeServerless, 0755)
+	setenv(t, "PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(backend))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for key, value := range map[string]string{
+		"PERSONAL_ACCESS_TOKEN":     "token",
+		"GITHUB_BASE_URL":           server.BaseURL(),
+		"GITHUB_RETRY_MAX_ATTEMPTS": "3",
+		"GITHUB_RETRY_BASE_DELAY":   "1ms",
+		"GITHUB_RETRY_MAX_DELAY":    "10ms",
+		"INFRA_ARTIFACT_STORE":      "local",
+		"INFRA_ARTIFACT_STORE_DIR":  filepath.Join(dir, "store"),
+		"CI":                        "true",
+		"GITHUB_WORKFLOW":           "test",
+		"GITHUB_RUN_ID":             "1",
+		"GITHUB_RUN_NUMBER":         "1",
+		"GITHUB_ACTION":             "run",
+		"GITHUB_ACTIONS":            "true",
+		"GITHUB_ACTOR":              "octocat",
+		"GITHUB_REPOSITORY":         "owner/repo",
+		"GITHUB_EVENT_NAME":         "repository_dispatch",
+		"GITHUB_EVENT_PATH":         filepath.Join(dir, "event.json"),
+		"GITHUB_WORKSPACE":          dir,
+		"GITHUB_SHA":                commitSHA,
+		"GITHUB_REF":                "refs/heads/master",
+		"GITHUB_SERVER_URL":         "https://github.example.com",
+		"ARTIFACT_SIGNING_KEY":      "",
+		"ARTIFACT_VERIFY_KEY":       "",
+		"GITHUB_APP_ID":             "",
+		"GITHUB_BASE_REF":           "",
+	} {
+		setenv(t, key, value)
+	}
+	return commitSHA
+}
+
+// dispatchPayload writes the repository_dispatch event of the dispatch, the workflow run it triggers reads it.
+func dispatchPayload(t *testing.T, dispatch githubtest.Dispatch) {
+	event, err := json.Marshal(map[string]interface{}{"action": dispatch.EventType, "client_payload": dispatch.ClientPayload})
+	require.NoError(t, err)
+	writeFile(t, os.Getenv("GITHUB_EVENT_PATH"), string(event), 0644)
+}
+
+func commitStatuses(server *githubtest.Server) []string {
+	var statuses []string
+	for _, status := range server.CommitStatuses() {
+		statuses = append(statuses, fmt.Sprintf("%s %s: %s", status.Context, status.State, status.Description))
+	}
+	return statuses
+}
+
+func TestPipeline(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+	commitSHA := setupPipeline(t, server)
+
+	// hash triggers the build of the changed service
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"hash", "--commit-sha", commitSHA}))
+	dispatches := server.Dispatches()
+	require.Len(t, dispatches, 1)
+	require.Equal(t, "backend-build demo-service", dispatches[0].EventType)
+	require.Equal(t, []string{"infra/demo-service pending: building"}, commitStatuses(server))
+
+	// build uploads the artifact and triggers the deploy to dev
+	dispatchPayload(t, dispatches[0])
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"build"}))
+	dispatches = server.Dispatches()
+	require.Len(t, dispatches, 2)
+	require.Equal(t, "backend-deploy demo-service @ dev", dispatches[1].EventType)
+	deployPayload, err := internal.DecodeEvent(dispatches[1].EventType, dispatches[1].ClientPayload)
+	require.NoError(t, err)
+	checksum := deployPayload.(*internal.BackendDeployEventPayload).Checksum
+	require.Equal(t, commitSHA, deployPayload.(*internal.BackendDeployEventPayload).CommitSHA)
+
+	// deploy runs serverless and reports the deployment
+	dispatchPayload(t, dispatches[1])
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"deploy"}))
+
+	serverlessLog, err := ioutil.ReadFile(filepath.Join("dist", "serverless.log"))
+	require.NoError(t, err)
+	require.Equal(t, "deploy --stage dev\ninfo --verbose --stage dev\n", string(serverlessLog))
+
+	deployments := server.Deployments()
+	require.Len(t, deployments, 1)
+	require.Equal(t, commitSHA, deployments[0].Ref)
+	require.Equal(t, "dev", deployments[0].Environment)
+	require.JSONEq(t, fmt.Sprintf(`{"service": "demo-service", "checksum": %q}`, checksum), string(deployments[0].Payload))
+
+	deploymentStatuses := server.DeploymentStatuses()
+	require.Len(t, deploymentStatuses, 2)
+	require.Equal(t, "in_progress", deploymentStatuses[0].State)
+	require.Equal(t, "success", deploymentStatuses[1].State)
+	require.Equal(t, "https://api.example.com/dev", deploymentStatuses[1].EnvironmentURL)
+	require.Equal(t, "https://github.example.com/owner/repo/actions/runs/1", deploymentStatuses[1].LogURL)
+
+	// plan runs serverless away from the deployed dist
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"plan", "--env", "dev", "--service", "demo-service"}))
+	serverlessLog, err = ioutil.ReadFile(filepath.Join("dist", "serverless.log"))
+	require.NoError(t, err)
+	require.Equal(t, "deploy --stage dev\ninfo --verbose --stage dev\n", string(serverlessLog))
+
+	// nothing changed since the build
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"hash", "--commit-sha", commitSHA, "--service", "demo-service"}))
+	require.Len(t, server.Dispatches(), 2)
+
+	require.Equal(t, []string{
+		"infra/demo-service pending: building",
+		"infra/demo-service pending: building",
+		"infra/demo-service pending: built " + checksum,
+		"infra/demo-service pending: deploying to dev",
+		"infra/demo-service success: deployed to dev",
+		"infra/demo-service success: unchanged",
+	}, commitStatuses(server))
+	for _, status := range server.CommitStatuses() {
+		require.Equal(t, commitSHA, status.SHA)
+	}
+}
+
+func TestPipelineDeployTimeout(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+	commitSHA := setupPipeline(t, server)
+
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"hash", "--commit-sha", commitSHA}))
+	dispatchPayload(t, server.Dispatches()[0])
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"build"}))
+	dispatchPayload(t, server.Dispatches()[1])
+
+	// the lock is free, a hung deploy is killed after --timeout however long --lock-wait is
+	setenv(t, "SERVERLESS_DEPLOY_SLEEP", "30")
+	start := time.Now()
+	require.Equal(t, cli.ExitFailure, cli.Run([]string{"deploy", "--lock-wait", "1h", "--timeout", "500ms"}))
+	require.True(t, time.Since(start) < 10*time.Second, "deploy not killed")
+
+	deploymentStatuses := server.DeploymentStatuses()
+	require.Equal(t, "failure", deploymentStatuses[len(deploymentStatuses)-1].State)
+}
+
+func TestPipelineGitHubErrors(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+	commitSHA := setupPipeline(t, server)
+
+	// the build event is dispatched once GitHub recovers
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusBadGateway, Times: 2})
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"hash", "--commit-sha", commitSHA}))
+	require.Len(t, server.Dispatches(), 1)
+
+	// commit statuses are best effort
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/statuses/", Status: http.StatusForbidden})
+	dispatchPayload(t, server.Dispatches()[0])
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"build"}))
+	require.Len(t, server.Dispatches(), 2)
+
+	// a deploy is not run for a build event
+	require.Equal(t, cli.ExitFailure, cli.Run([]string{"deploy"}))
+
+	// the deploy event is not best effort
+	dispatchPayload(t, server.Dispatches()[0])
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusUnauthorized})
+	require.Equal(t, cli.ExitFailure, cli.Run([]string{"build"}))
+	require.Len(t, server.Dispatches(), 2)
+	require.Equal(t, []string{"infra/demo-service pending: building"}, commitStatuses(server), "only the hash status got through")
+}
+
+func TestPipelineLocal(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+	commitSHA := setupPipeline(t, server)
+
+	// run by hand, only the repository of the GitHub Actions env is set
+	for _, key := range []string{
+		"CI", "GITHUB_WORKFLOW", "GITHUB_RUN_ID", "GITHUB_RUN_NUMBER", "GITHUB_ACTION", "GITHUB_ACTIONS", "GITHUB_ACTOR",
+		"GITHUB_EVENT_NAME", "GITHUB_EVENT_PATH", "GITHUB_WORKSPACE", "GITHUB_SHA", "GITHUB_REF", "GITHUB_SERVER_URL",
+	} {
+		setenv(t, key, "")
+	}
+
+	// the build event payload is read from env variables
+	setenv(t, "SERVICE", "demo-service")
+	setenv(t, "COMMIT_SHA", commitSHA)
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"build"}))
+	dispatches := server.Dispatches()
+	require.Len(t, dispatches, 1)
+	require.Equal(t, "backend-deploy demo-service @ dev", dispatches[0].EventType)
+	deployPayload, err := internal.DecodeEvent(dispatches[0].EventType, dispatches[0].ClientPayload)
+	require.NoError(t, err)
+
+	// and so is the deploy event payload
+	setenv(t, "ENV", "dev")
+	setenv(t, "CHECKSUM", deployPayload.(*internal.BackendDeployEventPayload).Checksum)
+	require.Equal(t, cli.ExitOK, cli.Run([]string{"deploy"}))
+
+	deploymentStatuses := server.DeploymentStatuses()
+	require.Len(t, deploymentStatuses, 2)
+	require.Equal(t, "success", deploymentStatuses[1].State)
+	require.Empty(t, deploymentStatuses[1].LogURL)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"infra/internal"
+)
+
+type planCmd struct {
+	env      string
+	service  string
+	checksum string
+	timeout  time.Duration
+}
+
+func (*planCmd) Name() string { return "plan" }
+
+func (*planCmd) Synopsis() string {
+	return "show the CloudFormation changes a deploy would make, without deploying"
+}
+
+func (c *planCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.env, "env", "", "environment")
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.StringVar(&c.checksum, "checksum", "", "service checksum (default: last built checksum)")
+	fs.DurationVar(&c.timeout, "timeout", 10*time.Minute, "serverless package timeout")
+}
+
+func (c *planCmd) Run(ctx context.Context) error {
+	if c.env == "" {
+		return newUsageError("`--env` not provided")
+	}
+
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	env, err := loadEnv()
+	if err != nil {
+		return err
+	}
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	checksum := c.checksum
+	if checksum == "" {
+		log.Print("getting last checksum")
+		checksum, err = bu.GetLastCodeChecksum()
+		if err != nil {
+			return err
+		}
+		log.Print(fmt.Sprintf("last code checksum: %s", checksum))
+	}
+
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	artifact, err := bu.DownloadDistZip(checksum, dir)
+	if err != nil {
+		return err
+	}
+
+	planCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return plan(planCtx, bu, c.env, artifact.Path)
+}
+
+// plan logs the deploy plan of the dist zip.
+func plan(ctx context.Context, bu *internal.BuildUtils, env string, distZipPath string) error {
+	p, err := bu.Plan(ctx, env, distZipPath)
+	if err != nil {
+		return err
+	}
+
+	log.Print(fmt.Sprintf("planned template:\n%s", p.Template))
+	if p.FirstDeployment {
+		log.Print(fmt.Sprintf("nothing deployed to %s yet", env))
+	}
+	if len(p.Changes) < 1 {
+		log.Print("no changes")
+		return nil
+	}
+	log.Print(fmt.Sprintf("%d change(s):", len(p.Changes)))
+	for _, change := range p.Changes {
+		log.Print(fmt.Sprintf("  %s", change))
+	}
+	return nil
+}
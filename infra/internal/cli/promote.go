@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"infra/internal"
+)
+
+type promoteCmd struct {
+	from    string
+	to      string
+	service string
+	order   string
+}
+
+func (*promoteCmd) Name() string { return "promote" }
+
+func (*promoteCmd) Synopsis() string {
+	return "trigger the deploy of the checksum live in an environment to the next one"
+}
+
+func (c *promoteCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.from, "from", "", "source environment")
+	fs.StringVar(&c.to, "to", "", "target environment")
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.StringVar(&c.order, "order", "", "comma separated promotion order (default: $PROMOTION_ORDER)")
+}
+
+func (c *promoteCmd) Run(ctx context.Context) error {
+	if c.from == "" {
+		return newUsageError("`--from` not provided")
+	}
+
+	if c.to == "" {
+		return newUsageError("`--to` not provided")
+	}
+
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	env, err := loadUserEnv()
+	if err != nil {
+		return err
+	}
+	if c.order != "" {
+		env.PromotionOrder = internal.ParsePromotionOrder(c.order)
+	}
+	log.Print(fmt.Sprintf("promotion order: %s", strings.Join(env.PromotionOrder, " -> ")))
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	source, err := bu.PromotionSource(env.PromotionOrder, c.from, c.to, env.SigningConfig)
+	if err != nil {
+		return err
+	}
+	log.Print(fmt.Sprintf("%s checksum: %s (deployed at %s)", c.from, source.Checksum, source.DeployedAt))
+
+	target, err := bu.GetDeployment(c.to)
+	if err != nil {
+		return err
+	}
+	if target != nil && target.Checksum == source.Checksum {
+		log.Print(fmt.Sprintf("%s checksum is already live on %s, nothing to do", c.from, c.to))
+		return nil
+	}
+
+	log.Print("triggering deploy event")
+	githubClient, err := env.githubClient(env.Repository)
+	if err != nil {
+		return err
+	}
+	eventPayload := internal.BackendDeployEventPayload{
+		Env:       c.to,
+		Service:   c.service,
+		Checksum:  source.Checksum,
+		CommitSHA: source.CommitSHA,
+	}
+	err = githubClient.DispatchEvent(ctx, &eventPayload)
+	if err != nil {
+		return err
+	}
+	log.Print("deploy event triggered")
+
+	log.Print("done")
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"infra/internal"
+)
+
+type rollbackCmd struct {
+	env     string
+	service string
+	steps   int
+}
+
+func (*rollbackCmd) Name() string { return "rollback" }
+
+func (*rollbackCmd) Synopsis() string {
+	return "trigger the deploy of a previously deployed checksum to an environment"
+}
+
+func (c *rollbackCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.env, "env", "", "environment")
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.IntVar(&c.steps, "steps", 1, "number of deployments to go back")
+}
+
+func (c *rollbackCmd) Run(ctx context.Context) error {
+	if c.env == "" {
+		return newUsageError("`--env` not provided")
+	}
+
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	env, err := loadUserEnv()
+	if err != nil {
+		return err
+	}
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	log.Print("looking up deployment history")
+	current, err := bu.GetDeployment(c.env)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		log.Print(fmt.Sprintf("live checksum: %s (deployed at %s)", current.Checksum, current.DeployedAt))
+	}
+
+	target, err := bu.RollbackTarget(c.env, c.steps, env.SigningConfig)
+	if err != nil {
+		return err
+	}
+	log.Print(fmt.Sprintf("rollback checksum: %s (deployed at %s)", target.Checksum, target.DeployedAt))
+
+	log.Print("triggering deploy event")
+	githubClient, err := env.githubClient(env.Repository)
+	if err != nil {
+		return err
+	}
+	// RollbackTarget found a target, current is not nil
+	eventPayload := internal.BackendDeployEventPayload{
+		Env:            c.env,
+		Service:        c.service,
+		Checksum:       target.Checksum,
+		CommitSHA:      target.CommitSHA,
+		RolledBackFrom: current.Checksum,
+	}
+	err = githubClient.DispatchEvent(ctx, &eventPayload)
+	if err != nil {
+		return err
+	}
+	log.Print("deploy event triggered")
+
+	log.Print("done")
+	return nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"infra/internal"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+type statusCmd struct {
+	service string
+	env     string
+	output  string
+}
+
+func (*statusCmd) Name() string { return "status" }
+
+func (*statusCmd) Synopsis() string {
+	return "show what is deployed in every environment"
+}
+
+func (c *statusCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.service, "service", "", "service id (default: every service)")
+	fs.StringVar(&c.env, "env", "", "environment (default: every environment)")
+	fs.StringVar(&c.output, "output", outputTable, "output format, table or json")
+}
+
+func (c *statusCmd) Run(ctx context.Context) error {
+	if c.output != outputTable && c.output != outputJSON {
+		return newUsageError("invalid `--output`: %s", c.output)
+	}
+
+	env, err := loadEnv()
+	if err != nil {
+		return err
+	}
+
+	store, err := env.artifactStore()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := internal.DeploymentStatuses(store, c.service, c.env)
+	if err != nil {
+		return err
+	}
+	if len(statuses) < 1 {
+		log.Print(fmt.Sprintf("no deployments found in the artifact store (service: %q, env: %q)", c.service, c.env))
+	}
+
+	if c.output == outputJSON {
+		if statuses == nil {
+			statuses = []internal.DeploymentStatus{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tENV\tCHECKSUM\tCOMMIT\tAGE\tLAST CHECKSUM\tBEHIND")
+	now := time.Now()
+	for _, status := range statuses {
+		behind := "no"
+		if status.Behind {
+			behind = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			status.Service,
+			status.Env,
+			shorten(status.Checksum, 12),
+			shorten(status.CommitSHA, 7),
+			formatAge(now.Sub(status.DeployedAt)),
+			shorten(status.LastChecksum, 12),
+			behind,
+		)
+	}
+	return w.Flush()
+}
+
+func shorten(s string, n int) string {
+	if s == "" {
+		return "-"
+	}
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// formatAge formats d with a precision of minutes, e.g. "2d4h", "3h12m", "5m".
+func formatAge(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type unlockCmd struct {
+	env     string
+	service string
+	force   bool
+}
+
+func (*unlockCmd) Name() string { return "unlock" }
+
+func (*unlockCmd) Synopsis() string {
+	return "release a stale deploy lock of a service environment"
+}
+
+func (c *unlockCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.env, "env", "", "environment")
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.BoolVar(&c.force, "force", false, "release the lock even if it did not expire")
+}
+
+func (c *unlockCmd) Run(ctx context.Context) error {
+	if c.env == "" {
+		return newUsageError("`--env` not provided")
+	}
+
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	env, err := loadEnv()
+	if err != nil {
+		return err
+	}
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	lock, err := bu.GetDeployLock(c.env)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		log.Print("not locked, nothing to do")
+		return nil
+	}
+	log.Print(fmt.Sprintf("deploy lock: %s", lock))
+
+	if !lock.Expired(time.Now()) && !c.force {
+		return errors.New("deploy lock did not expire, a deploy may still be running (use `--force` to release it anyway)")
+	}
+
+	err = bu.ForceReleaseDeployLock(c.env)
+	if err != nil {
+		return err
+	}
+	log.Print("deploy lock released")
+	return nil
+}
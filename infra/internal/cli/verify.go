@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"infra/internal"
+)
+
+type verifyCmd struct {
+	service  string
+	checksum string
+	key      string
+}
+
+func (*verifyCmd) Name() string { return "verify" }
+
+func (*verifyCmd) Synopsis() string {
+	return "verify a service checksum dist zip against its manifest and the manifest signature"
+}
+
+func (c *verifyCmd) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.service, "service", "", "service id")
+	fs.StringVar(&c.checksum, "checksum", "", "service checksum")
+	fs.StringVar(&c.key, "key", "", "base64 encoded ed25519 public key (default: $ARTIFACT_VERIFY_KEY)")
+}
+
+func (c *verifyCmd) Run(ctx context.Context) error {
+	if c.service == "" {
+		return newUsageError("`--service` not provided")
+	}
+
+	if c.checksum == "" {
+		return newUsageError("`--checksum` not provided")
+	}
+
+	env, err := loadEnv()
+	if err != nil {
+		return err
+	}
+	if c.key != "" {
+		env.ArtifactVerifyKey = c.key
+	}
+
+	verifyKey, err := env.verifyKey()
+	if err != nil {
+		return err
+	}
+	if verifyKey == nil {
+		return newUsageError("no verify key, `--key` not provided and ARTIFACT_VERIFY_KEY not set")
+	}
+
+	bu, err := env.buildUtils(c.service)
+	if err != nil {
+		return err
+	}
+
+	artifact, _, err := bu.FetchArtifact(c.checksum)
+	if err != nil {
+		return err
+	}
+
+	err = artifact.VerifySignature(verifyKey)
+	if err != nil {
+		return err
+	}
+
+	m := artifact.Manifest
+	log.Print(fmt.Sprintf("%s checksum %s: signature ok", c.service, c.checksum))
+	log.Print(fmt.Sprintf("  commit: %s, built at %s by run %s", m.CommitSHA, m.BuiltAt, m.BuilderRunID))
+	log.Print(fmt.Sprintf("  dist zip sha256: %s (%d files)", m.ZipSHA256, len(m.Files)))
+	return nil
+}
+
+type keygenCmd struct{}
+
+func (*keygenCmd) Name() string { return "keygen" }
+
+func (*keygenCmd) Synopsis() string {
+	return "generate an ed25519 key pair for ARTIFACT_SIGNING_KEY and ARTIFACT_VERIFY_KEY"
+}
+
+func (*keygenCmd) SetFlags(fs *flag.FlagSet) {}
+
+func (*keygenCmd) Run(ctx context.Context) error {
+	signingKey, verifyKey, err := internal.GenerateSigningKeys()
+	if err != nil {
+		return errors.Wrap(err, "failed to generate keys")
+	}
+	fmt.Printf("ARTIFACT_SIGNING_KEY=%s\n", signingKey)
+	fmt.Printf("ARTIFACT_VERIFY_KEY=%s\n", verifyKey)
+	return nil
+}
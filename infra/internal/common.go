@@ -1,20 +1,16 @@
 package internal
 
 import (
-	"bytes"
-	"crypto/sha1"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	aws_session "github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -27,13 +23,13 @@ const (
 )
 
 type BuildUtils struct {
-	bucket string
+	store ArtifactStore
 
 	service string
 }
 
-func NewBuildUtils(bucket, service string) (*BuildUtils, error) {
-	return &BuildUtils{bucket: bucket, service: service}, nil
+func NewBuildUtils(store ArtifactStore, service string) (*BuildUtils, error) {
+	return &BuildUtils{store: store, service: service}, nil
 }
 
 func (bu *BuildUtils) binariesPattern() string {
@@ -48,179 +44,246 @@ func (bu *BuildUtils) checksumDistZipKey(checksum string) string {
 	return filepath.Join(bu.service, checksum, distZip)
 }
 
-func (bu *BuildUtils) download(key string) ([]byte, error) {
-	session, err := aws_session.NewSession()
+func (bu *BuildUtils) GenerateDistZip() ([]byte, error) {
+	fPaths, err := filepath.Glob(bu.binariesPattern())
 	if err != nil {
 		return nil, err
 	}
+	if len(fPaths) < 1 {
+		return nil, errors.New("no binaries files found")
+	}
+	fPaths = append(fPaths, filepath.Join(bu.service, serverlessYML))
 
-	buf := aws.NewWriteAtBuffer([]byte{})
-	downloader := s3manager.NewDownloader(session)
-	_, err = downloader.Download(buf, &s3.GetObjectInput{
-		Bucket: aws.String(bu.bucket),
-		Key:    aws.String(key),
+	return zipFiles(fPaths, func(fPath string) (string, error) {
+		return filepath.Rel(bu.service, fPath)
 	})
-	if err != nil {
-		return nil, err
-	}
+}
 
-	return buf.Bytes(), nil
+// DistZipDigest is the hex encoded SHA-256 of the dist zip.
+func DistZipDigest(zipData []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(zipData))
 }
 
-func (bu *BuildUtils) upload(key string, data []byte) error {
-	session, err := aws_session.NewSession()
+// UploadDistZip uploads the dist zip and its manifest,
+// when signingKey is not nil also the manifest detached signature ("<service>/<checksum>/manifest.json.sig").
+func (bu *BuildUtils) UploadDistZip(manifest *ArtifactManifest, zipData []byte, signingKey ed25519.PrivateKey) error {
+	err := manifest.Verify(zipData)
 	if err != nil {
 		return err
 	}
 
-	uploader := s3manager.NewUploader(session)
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(bu.bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
-	})
-	return err
-}
-
-func (bu *BuildUtils) ComputeCodeChecksum() (string, error) {
-	hash := sha1.New()
-	err := filepath.Walk(bu.service, func(fPath string, fInfo os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal artifact manifest")
+	}
 
-		if fInfo.IsDir() {
-			return nil
-		}
+	err = bu.store.Put(bu.checksumDistZipKey(manifest.Checksum), zipData)
+	if err != nil {
+		return err
+	}
 
-		isBin, err := filepath.Match(bu.binariesPattern(), fPath)
+	if signingKey != nil {
+		signature := ed25519.Sign(signingKey, manifestData)
+		err = bu.store.Put(
+			bu.checksumManifestSignatureKey(manifest.Checksum),
+			[]byte(base64.StdEncoding.EncodeToString(signature)),
+		)
 		if err != nil {
 			return err
 		}
-		if isBin {
-			return nil
-		}
+	}
 
-		fReader, err := os.Open(fPath)
-		if err != nil {
-			return err
-		}
+	// uploaded last, a dist zip without manifest can't be deployed
+	return bu.store.Put(bu.checksumManifestKey(manifest.Checksum), manifestData)
+}
 
-		_, err = hash.Write([]byte(fPath))
-		if err != nil {
-			return err
-		}
+// DistArtifact is a dist zip verified against its manifest.
+type DistArtifact struct {
+	Checksum string
+	// Path of the downloaded dist zip.
+	Path     string
+	Manifest *ArtifactManifest
+	// The manifest as stored, i.e. as signed.
+	ManifestData []byte
+	// Detached signature of ManifestData, nil if the artifact is not signed.
+	Signature []byte
+}
 
-		content, err := ioutil.ReadAll(fReader)
-		if err != nil {
-			return err
+// FetchArtifact gets the dist zip of checksum, its manifest and signature and verifies the dist zip against the manifest.
+func (bu *BuildUtils) FetchArtifact(checksum string) (*DistArtifact, []byte, error) {
+	manifestData, err := bu.store.Get(bu.checksumManifestKey(checksum))
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, nil, errors.New(fmt.Sprintf("manifest of checksum %s not found, dist zip can't be verified", checksum))
 		}
+		return nil, nil, err
+	}
 
-		_, err = hash.Write(content)
-		if err != nil {
-			return err
-		}
-		return nil
-	})
+	manifest := ArtifactManifest{}
+	err = json.Unmarshal(manifestData, &manifest)
 	if err != nil {
-		return "", err
+		return nil, nil, errors.Wrap(err, "failed to unmarshal artifact manifest")
+	}
+	if manifest.Service != bu.service || manifest.Checksum != checksum {
+		return nil, nil, errors.New(fmt.Sprintf(
+			"manifest is for %s checksum %s, expected %s checksum %s",
+			manifest.Service, manifest.Checksum, bu.service, checksum,
+		))
 	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil)), nil
-}
-
-func (bu *BuildUtils) GetLastCodeChecksum() (string, error) {
-	data, err := bu.download(bu.lastCodeChecksumKey())
+	signature, err := bu.getManifestSignature(checksum)
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			switch awsErr.Code() {
-			case s3.ErrCodeNoSuchKey:
-				return "", nil // service was never deployed
-			}
-		}
-		return "", err
+		return nil, nil, err
 	}
 
-	return string(data), nil
-}
-
-func (bu *BuildUtils) SetLastCodeChecksum(checksum string) error {
-	return bu.upload(bu.lastCodeChecksumKey(), []byte(checksum))
-}
-
-func (bu *BuildUtils) GenerateDistZip() ([]byte, error) {
-	fPaths, err := filepath.Glob(bu.binariesPattern())
+	data, err := bu.store.Get(bu.checksumDistZipKey(checksum))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if len(fPaths) < 1 {
-		return nil, errors.New("no binaries files found")
-	}
-	fPaths = append(fPaths, filepath.Join(bu.service, serverlessYML))
 
-	return zipFiles(fPaths, func(fPath string) (string, error) {
-		return filepath.Rel(bu.service, fPath)
-	})
-}
+	err = manifest.Verify(data)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "dist zip verification failed")
+	}
+	log.Print(fmt.Sprintf("dist zip verified, sha256: %s", manifest.ZipSHA256))
 
-func (bu *BuildUtils) UploadDistZip(checksum string, zipData []byte) error {
-	return bu.upload(bu.checksumDistZipKey(checksum), zipData)
+	return &DistArtifact{Checksum: checksum, Manifest: &manifest, ManifestData: manifestData, Signature: signature}, data, nil
 }
 
-func (bu *BuildUtils) DownloadDistZip(checksum string) (string, error) {
-	data, err := bu.download(bu.checksumDistZipKey(checksum))
+// DownloadDistZip downloads the dist zip of checksum to dir, verified against its manifest.
+func (bu *BuildUtils) DownloadDistZip(checksum string, dir string) (*DistArtifact, error) {
+	artifact, data, err := bu.FetchArtifact(checksum)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	f, err := os.Create(distZip)
+	distZipPath := filepath.Join(dir, distZip)
+	f, err := os.Create(distZipPath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer f.Close()
 	_, err = f.Write(data)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return distZip, nil
+	artifact.Path = distZipPath
+	return artifact, nil
 }
 
-func (bu *BuildUtils) Deploy(env string, distZipPath string) error {
-	distPath := "dist"
-	err := unzipFiles(distZipPath, distPath)
+// unpackDistZip unzips the dist zip to distPath, replacing its content, serverless commands run from distPath.
+func (bu *BuildUtils) unpackDistZip(distZipPath string, distPath string) error {
+	err := os.RemoveAll(distPath)
 	if err != nil {
 		return err
 	}
 
-	fPaths, err := filepath.Glob("dist/.bin/*")
+	err = unzipFiles(distZipPath, distPath)
+	if err != nil {
+		return err
+	}
+
+	fPaths, err := filepath.Glob(filepath.Join(distPath, binariesDir, "*"))
 	if err != nil {
 		return err
 	}
 	if len(fPaths) < 1 {
 		return errors.New("no binaries files found")
 	}
+	return nil
+}
 
-	// make bin files executable again....
-	for _, fPath := range fPaths {
-		err := os.Chmod(fPath, 0775)
-		if err != nil {
-			return err
+type DeployOptions struct {
+	SigningConfig *SigningConfig
+	// Artifacts not signed with VerifyKey are refused by environments protected by SigningConfig.
+	VerifyKey ed25519.PublicKey
+	// Waiting for the lock is bounded by Lock.Wait, its TTL counts from when it is acquired.
+	Lock LockOptions
+	// How long `serverless deploy` may run once the lock is held, unbounded if 0.
+	Timeout time.Duration
+	// Recorded with the deployment, see DeploymentRecord.
+	CommitSHA string
+	Actor     string
+	RunID     string
+	// Checksum rolled back from, if the deploy is a rollback.
+	RolledBackFrom string
+}
+
+// Deploy runs `serverless deploy` on the unzipped dist zip, the deploy is aborted when ctx is done or opts.Timeout elapsed.
+// The deploy lock of env is held while deploying, opts.Timeout starts once it is acquired.
+// The deployment and the deployed CloudFormation template are recorded, plans of later deploys are compared against it.
+func (bu *BuildUtils) Deploy(ctx context.Context, env string, artifact *DistArtifact, opts DeployOptions) (record *DeploymentRecord, err error) {
+	err = artifact.checkSignature(env, opts.SigningConfig, opts.VerifyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := bu.AcquireDeployLock(ctx, env, opts.Lock)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		releaseErr := bu.ReleaseDeployLock(lock)
+		if releaseErr != nil {
+			if err == nil {
+				err = releaseErr
+			} else {
+				log.Print(fmt.Sprintf("failed to release deploy lock: %s", releaseErr))
+			}
 		}
+	}()
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	distPath := "dist"
+	err = bu.unpackDistZip(artifact.Path, distPath)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.Command("serverless", "deploy", "--stage", env)
-	log.Print(fmt.Sprintf("running command: %s", cmd.String()))
-	cmd.Dir = distPath
-	stdout := bytes.Buffer{}
-	stderr := bytes.Buffer{}
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err = cmd.Run()
-	log.Print("command output:")
-	log.Print(stdout.String())
+	err = runStreaming(ctx, distPath, "serverless", "deploy", "--stage", env)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+
+	// the service is deployed, a missing endpoint is not worth failing the deploy
+	endpoint, err := serviceEndpoint(ctx, distPath, env)
+	if err != nil {
+		log.Print(fmt.Sprintf("failed to get the service endpoint: %s", err))
+	}
+
+	commitSHA := opts.CommitSHA
+	if commitSHA == "" {
+		commitSHA = artifact.Manifest.CommitSHA
+	}
+
+	log.Print("recording deployment")
+	record = &DeploymentRecord{
+		Service:        bu.service,
+		Env:            env,
+		Checksum:       artifact.Checksum,
+		CommitSHA:      commitSHA,
+		DeployedAt:     time.Now().UTC(),
+		Actor:          opts.Actor,
+		RunID:          opts.RunID,
+		Endpoint:       endpoint,
+		RolledBackFrom: opts.RolledBackFrom,
+	}
+	err = bu.RecordDeployment(*record)
+	if err != nil {
+		return nil, err
+	}
+	log.Print("deployment recorded")
+
+	// the service is deployed, the next plan is only less accurate
+	err = bu.recordDeployedTemplate(env, distPath)
+	if err != nil {
+		log.Print(fmt.Sprintf("failed to record the deployed template: %s", err))
+	}
+	return record, nil
 }
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	deploymentsDir        = "deployments"
+	currentDeploymentKey  = "current.json"
+	deploymentHistoryKey  = "history.json"
+	maxDeploymentsHistory = 100
+)
+
+// DeploymentRecord describes a successful deployment of a service to an environment.
+type DeploymentRecord struct {
+	Service    string    `json:"service"`
+	Env        string    `json:"env"`
+	Checksum   string    `json:"checksum"`
+	CommitSHA  string    `json:"commitSHA"`
+	DeployedAt time.Time `json:"deployedAt"`
+	Actor      string    `json:"actor"`
+	RunID      string    `json:"runID"`
+	// API Gateway url of the service, if it has http events.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Checksum live before the deployment if it is a rollback, checksums rolled back from are never rolled back to.
+	RolledBackFrom string `json:"rolledBackFrom,omitempty"`
+}
+
+// "<service>/deployments/<env>/current.json" holds the live deployment,
+// "<service>/deployments/<env>/history.json" every deployment, newest first.
+func (bu *BuildUtils) deploymentKey(env string, name string) string {
+	return filepath.Join(bu.service, deploymentsDir, env, name)
+}
+
+// GetDeployment returns what is live in env, nil if the service was never deployed there.
+func (bu *BuildUtils) GetDeployment(env string) (*DeploymentRecord, error) {
+	data, err := bu.store.Get(bu.deploymentKey(env, currentDeploymentKey))
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	record := DeploymentRecord{}
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal deployment record")
+	}
+	return &record, nil
+}
+
+// GetDeploymentHistory returns the deployments to env, newest first.
+func (bu *BuildUtils) GetDeploymentHistory(env string) ([]DeploymentRecord, error) {
+	data, err := bu.store.Get(bu.deploymentKey(env, deploymentHistoryKey))
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var history []DeploymentRecord
+	err = json.Unmarshal(data, &history)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal deployment history")
+	}
+	return history, nil
+}
+
+// RecordDeployment makes record the live deployment of its environment and adds it to the history.
+func (bu *BuildUtils) RecordDeployment(record DeploymentRecord) error {
+	record.Service = bu.service
+	if record.Env == "" {
+		return errors.New("deployment record env not set")
+	}
+
+	history, err := bu.GetDeploymentHistory(record.Env)
+	if err != nil {
+		return err
+	}
+	history = append([]DeploymentRecord{record}, history...)
+	if len(history) > maxDeploymentsHistory {
+		history = history[:maxDeploymentsHistory]
+	}
+
+	historyData, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal deployment history")
+	}
+	err = bu.store.Put(bu.deploymentKey(record.Env, deploymentHistoryKey), historyData)
+	if err != nil {
+		return err
+	}
+
+	recordData, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal deployment record")
+	}
+	return bu.store.Put(bu.deploymentKey(record.Env, currentDeploymentKey), recordData)
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"strings"
+)
+
+const serviceEndpointOutput = "ServiceEndpoint:"
+
+// serviceEndpoint returns the "ServiceEndpoint" stack output listed by `serverless info --verbose`,
+// the API Gateway url of the service, empty if the service has no http events.
+func serviceEndpoint(ctx context.Context, distPath string, env string) (string, error) {
+	info, err := runOutput(ctx, distPath, "serverless", "info", "--verbose", "--stage", env)
+	if err != nil {
+		return "", err
+	}
+	return parseServiceEndpoint(info), nil
+}
+
+func parseServiceEndpoint(info string) string {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, serviceEndpointOutput) {
+			return strings.TrimSpace(strings.TrimPrefix(line, serviceEndpointOutput))
+		}
+	}
+	return ""
+}
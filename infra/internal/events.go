@@ -1,53 +1,329 @@
 package internal
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
 )
 
-const (
-	backendBuildEventTypePrefix = "backend-build"
+// ErrInvalidEvent is returned for unknown event types and malformed or outdated event payloads.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// GitHub rejects repository dispatch event types longer than that.
+const maxEventTypeLength = 100
+
+var (
+	// names of environments, part of event types and artifact store keys
+	eventNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+	// service ids, "/" separated names as nested services are found, e.g. "group/beta-service"
+	servicePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*(/[a-zA-Z0-9][a-zA-Z0-9_-]*)*$`)
+	// hex encoded sha1, of code checksums and commits
+	sha1Pattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
 )
 
-func BackendBuildEventType(service string) string {
-	return fmt.Sprintf("%s %s", backendBuildEventTypePrefix, service)
+// Event is the definition of a repository_dispatch event of the pipeline.
+type Event struct {
+	// Name starts the event type, e.g. "backend-build", the workflows are triggered by it.
+	Name string
+	// Version of the payload schema, payloads of any other version are rejected.
+	// Bumped on incompatible payload changes.
+	Version int
+	// Whether the event type includes the environment, "<name> <service> @ <env>", or only "<name> <service>".
+	HasEnv bool
+
+	newPayload func() EventPayload
 }
 
-type BackendBuildEventPayload struct {
-	CommitSHA string `json:"commitSHA"`
-	Service   string `json:"service" envconfig:"SERVICE" required:"true"`
+// EventPayload is the client payload of an event.
+type EventPayload interface {
+	// EventType is the type the payload is dispatched with.
+	EventType() EventType
+	// Validate returns an error if a field is missing or malformed.
+	Validate() error
+
+	schema() *EventSchema
+}
+
+// EventSchema is embedded in every event payload.
+type EventSchema struct {
+	SchemaVersion int `json:"schemaVersion" ignored:"true"`
+}
+
+func (s *EventSchema) schema() *EventSchema {
+	return s
+}
+
+var events = map[string]*Event{}
+
+func registerEvent(event Event) *Event {
+	if _, ok := events[event.Name]; ok {
+		panic(fmt.Sprintf("event %s registered twice", event.Name))
+	}
+	events[event.Name] = &event
+	return &event
+}
+
+var (
+	BackendBuildEvent = registerEvent(Event{
+		Name:       "backend-build",
+		Version:    1,
+		newPayload: func() EventPayload { return &BackendBuildEventPayload{} },
+	})
+	BackendDeployEvent = registerEvent(Event{
+		Name:       "backend-deploy",
+		Version:    1,
+		HasEnv:     true,
+		newPayload: func() EventPayload { return &BackendDeployEventPayload{} },
+	})
+)
+
+// EventType is the parsed type of a repository_dispatch event.
+type EventType struct {
+	Name    string
+	Service string
+	// Empty for events without environment.
+	Env string
+}
+
+func (t EventType) String() string {
+	if t.Env == "" {
+		return fmt.Sprintf("%s %s", t.Name, t.Service)
+	}
+	return fmt.Sprintf("%s %s @ %s", t.Name, t.Service, t.Env)
+}
+
+// ParseEventType parses the type of a registered event, e.g. "backend-deploy demo-service @ dev".
+func ParseEventType(eventType string) (*Event, EventType, error) {
+	fields := strings.Split(eventType, " ")
+	event, ok := events[fields[0]]
+	if !ok {
+		return nil, EventType{}, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("unknown event type: %q", eventType))
+	}
+
+	parsed := EventType{Name: event.Name}
+	switch {
+	case !event.HasEnv && len(fields) == 2:
+		parsed.Service = fields[1]
+	case event.HasEnv && len(fields) == 4 && fields[2] == "@":
+		parsed.Service, parsed.Env = fields[1], fields[3]
+	default:
+		return nil, EventType{}, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("malformed %s event type: %q", event.Name, eventType))
+	}
+
+	err := validatePattern("service", servicePattern, parsed.Service)
+	if err == nil && event.HasEnv {
+		err = validateEventName("env", parsed.Env)
+	}
+	if err != nil {
+		return nil, EventType{}, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("malformed %s event type: %q: %s", event.Name, eventType, err))
+	}
+	return event, parsed, nil
+}
+
+// EncodeEvent validates the payload, sets its schema version and returns its event type and the client payload.
+func EncodeEvent(payload EventPayload) (string, []byte, error) {
+	eventType := payload.EventType().String()
+	event, _, err := ParseEventType(eventType)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(eventType) > maxEventTypeLength {
+		return "", nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("event type longer than %d characters: %q", maxEventTypeLength, eventType))
+	}
+
+	payload.schema().SchemaVersion = event.Version
+	err = payload.Validate()
+	if err != nil {
+		return "", nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("%s event payload: %s", event.Name, err))
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to marshal event payload")
+	}
+	return eventType, data, nil
 }
 
-func LoadBackendBuildEventPayloadFromEnv() (*BackendBuildEventPayload, error) {
-	eventPayload := BackendBuildEventPayload{}
-	err := envconfig.Process("", &eventPayload)
+// DecodeEvent decodes the client payload of the event type, rejecting
+// unknown event types, payloads of other schema versions, with unknown fields, invalid or not matching the event type.
+func DecodeEvent(eventType string, clientPayload []byte) (EventPayload, error) {
+	event, parsed, err := ParseEventType(eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := event.newPayload()
+	decoder := json.NewDecoder(bytes.NewReader(clientPayload))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(payload)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("malformed %s event payload: %s", event.Name, err))
+	}
+
+	if version := payload.schema().SchemaVersion; version != event.Version {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("%s event payload schema version %d, expected %d", event.Name, version, event.Version))
+	}
+	err = payload.Validate()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("%s event payload: %s", event.Name, err))
+	}
+	if payloadType := payload.EventType(); payloadType != parsed {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("%s event payload is for %q, dispatched as %q", event.Name, payloadType, eventType))
+	}
+	return payload, nil
+}
+
+func validateEventName(field string, value string) error {
+	return validatePattern(field, eventNamePattern, value)
+}
+
+func validatePattern(field string, pattern *regexp.Regexp, value string) error {
+	if !pattern.MatchString(value) {
+		return errors.New(fmt.Sprintf("invalid %s: %q", field, value))
+	}
+	return nil
+}
+
+// repository_dispatch webhook event, as read from GITHUB_EVENT_PATH.
+const repositoryDispatchEventName = "repository_dispatch"
+
+type repositoryDispatchEvent struct {
+	// The event type the event was dispatched with.
+	Action        string          `json:"action"`
+	ClientPayload json.RawMessage `json:"client_payload"`
+}
+
+// ReadDispatchEvent reads the repository_dispatch event that triggered the workflow run and decodes its client payload, see DecodeEvent.
+func ReadDispatchEvent(githubEnv *GitHubEnv) (EventPayload, error) {
+	if githubEnv.GitHubEventName != repositoryDispatchEventName {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf(
+			"workflow triggered by a %s event, expected %s", githubEnv.GitHubEventName, repositoryDispatchEventName,
+		))
+	}
+
+	data, err := ioutil.ReadFile(githubEnv.GitHubEventPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read workflow event")
+	}
+	event := repositoryDispatchEvent{}
+	err = json.Unmarshal(data, &event)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("malformed %s event: %s", repositoryDispatchEventName, err))
+	}
+	return DecodeEvent(event.Action, event.ClientPayload)
+}
+
+// loadEventPayload loads the payload of the event that triggered the workflow run, which must be an event of the expected kind.
+// When not running on GitHub Actions, e.g. locally, the payload is loaded from env variables instead.
+func loadEventPayload(githubEnv *GitHubEnv, event *Event) (EventPayload, error) {
+	if !RunningOnGitHubActions() {
+		log.Print("not running on GitHub Actions, loading the event payload from env variables")
+		return loadEventPayloadFromEnv(event)
+	}
+
+	payload, err := ReadDispatchEvent(githubEnv)
+	if err != nil {
+		return nil, err
+	}
+	if eventType := payload.EventType(); eventType.Name != event.Name {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("workflow triggered by a %q event, expected a %s event", eventType, event.Name))
+	}
+	return payload, nil
+}
+
+func loadEventPayloadFromEnv(event *Event) (EventPayload, error) {
+	payload := event.newPayload()
+	err := envconfig.Process("", payload)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load event payload")
 	}
-	return &eventPayload, nil
+	payload.schema().SchemaVersion = event.Version
+	err = payload.Validate()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidEvent, fmt.Sprintf("%s event payload: %s", event.Name, err))
+	}
+	return payload, nil
 }
 
-const (
-	backendDeployEventTypePrefix = "backend-deploy"
-)
+type BackendBuildEventPayload struct {
+	EventSchema
+	CommitSHA string `json:"commitSHA" envconfig:"COMMIT_SHA" required:"true"`
+	Service   string `json:"service" envconfig:"SERVICE" required:"true"`
+}
 
-func BackendDeployEventType(service string, env string) string {
-	return fmt.Sprintf("%s %s @ %s", backendDeployEventTypePrefix, service, env)
+func (p *BackendBuildEventPayload) EventType() EventType {
+	return EventType{Name: BackendBuildEvent.Name, Service: p.Service}
+}
+
+func (p *BackendBuildEventPayload) Validate() error {
+	err := validatePattern("service", servicePattern, p.Service)
+	if err != nil {
+		return err
+	}
+	return validatePattern("commitSHA", sha1Pattern, p.CommitSHA)
+}
+
+// LoadBackendBuildEventPayload loads the payload of the backend-build event that triggered the workflow run, see loadEventPayload.
+func LoadBackendBuildEventPayload(githubEnv *GitHubEnv) (*BackendBuildEventPayload, error) {
+	payload, err := loadEventPayload(githubEnv, BackendBuildEvent)
+	if err != nil {
+		return nil, err
+	}
+	return payload.(*BackendBuildEventPayload), nil
 }
 
 type BackendDeployEventPayload struct {
+	EventSchema
 	Env      string `json:"env" envconfig:"ENV" required:"true"`
 	Service  string `json:"service" envconfig:"SERVICE" required:"true"`
 	Checksum string `json:"checksum" envconfig:"CHECKSUM" required:"true"`
+	// Optional, the commit of the artifact manifest is used if empty.
+	CommitSHA string `json:"commitSHA" envconfig:"COMMIT_SHA"`
+	// Set by rollbacks, the checksum live when rolling back, see DeploymentRecord.RolledBackFrom.
+	RolledBackFrom string `json:"rolledBackFrom,omitempty" envconfig:"ROLLED_BACK_FROM"`
 }
 
-func LoadBackendDeployEventPayloadFromEnv() (*BackendDeployEventPayload, error) {
-	eventPayload := BackendDeployEventPayload{}
-	err := envconfig.Process("", &eventPayload)
+func (p *BackendDeployEventPayload) EventType() EventType {
+	return EventType{Name: BackendDeployEvent.Name, Service: p.Service, Env: p.Env}
+}
+
+func (p *BackendDeployEventPayload) Validate() error {
+	err := validatePattern("service", servicePattern, p.Service)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to load event payload")
+		return err
+	}
+	err = validateEventName("env", p.Env)
+	if err != nil {
+		return err
+	}
+	err = validatePattern("checksum", sha1Pattern, p.Checksum)
+	if err != nil {
+		return err
+	}
+	if p.CommitSHA != "" {
+		err = validatePattern("commitSHA", sha1Pattern, p.CommitSHA)
+		if err != nil {
+			return err
+		}
+	}
+	if p.RolledBackFrom != "" {
+		return validatePattern("rolledBackFrom", sha1Pattern, p.RolledBackFrom)
+	}
+	return nil
+}
+
+// LoadBackendDeployEventPayload loads the payload of the backend-deploy event that triggered the workflow run, see loadEventPayload.
+func LoadBackendDeployEventPayload(githubEnv *GitHubEnv) (*BackendDeployEventPayload, error) {
+	payload, err := loadEventPayload(githubEnv, BackendDeployEvent)
+	if err != nil {
+		return nil, err
 	}
-	return &eventPayload, nil
+	return payload.(*BackendDeployEventPayload), nil
 }
@@ -0,0 +1,117 @@
+package internal_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+const (
+	testChecksum  = "0123456789abcdef0123456789abcdef01234567"
+	testCommitSHA = "89abcdef0123456789abcdef0123456789abcdef"
+)
+
+func TestParseEventType(t *testing.T) {
+	event, eventType, err := internal.ParseEventType("backend-deploy demo-service @ dev")
+	require.NoError(t, err)
+	require.Equal(t, internal.BackendDeployEvent, event)
+	require.Equal(t, internal.EventType{Name: "backend-deploy", Service: "demo-service", Env: "dev"}, eventType)
+	require.Equal(t, "backend-deploy demo-service @ dev", eventType.String())
+
+	event, eventType, err = internal.ParseEventType("backend-build demo-service")
+	require.NoError(t, err)
+	require.Equal(t, internal.BackendBuildEvent, event)
+	require.Equal(t, internal.EventType{Name: "backend-build", Service: "demo-service"}, eventType)
+
+	// nested services, as found by DiscoverServices
+	_, eventType, err = internal.ParseEventType("backend-deploy group/beta-service @ dev")
+	require.NoError(t, err)
+	require.Equal(t, "group/beta-service", eventType.Service)
+
+	for _, invalid := range []string{
+		"",
+		"frontend-build demo-service",
+		"backend-build",
+		"backend-build demo-service @ dev",
+		"backend-deploy demo-service",
+		"backend-deploy demo-service dev",
+		"backend-deploy ../demo-service @ dev",
+		"backend-deploy group/../demo-service @ dev",
+		"backend-deploy /demo-service @ dev",
+		"backend-deploy group//demo-service @ dev",
+		"backend-deploy group/demo-service/ @ dev",
+		"backend-deploy demo-service @ group/dev",
+	} {
+		_, _, err := internal.ParseEventType(invalid)
+		require.True(t, errors.Is(err, internal.ErrInvalidEvent), invalid)
+	}
+}
+
+func TestEncodeDecodeEvent(t *testing.T) {
+	eventType, clientPayload, err := internal.EncodeEvent(&internal.BackendDeployEventPayload{
+		Env:       "dev",
+		Service:   "demo-service",
+		Checksum:  testChecksum,
+		CommitSHA: testCommitSHA,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "backend-deploy demo-service @ dev", eventType)
+
+	payload, err := internal.DecodeEvent(eventType, clientPayload)
+	require.NoError(t, err)
+	require.Equal(t, &internal.BackendDeployEventPayload{
+		EventSchema: internal.EventSchema{SchemaVersion: internal.BackendDeployEvent.Version},
+		Env:         "dev",
+		Service:     "demo-service",
+		Checksum:    testChecksum,
+		CommitSHA:   testCommitSHA,
+	}, payload)
+
+	// invalid payloads are not dispatched
+	_, _, err = internal.EncodeEvent(&internal.BackendBuildEventPayload{Service: "demo-service", CommitSHA: "HEAD"})
+	require.True(t, errors.Is(err, internal.ErrInvalidEvent))
+
+	for name, invalid := range map[string]string{
+		"malformed":        `{"schemaVersion": 1, "service": "demo-service"`,
+		"unknown field":    `{"schemaVersion": 1, "service": "demo-service", "commitSHA": "` + testCommitSHA + `", "env": "dev"}`,
+		"other version":    `{"schemaVersion": 2, "service": "demo-service", "commitSHA": "` + testCommitSHA + `"}`,
+		"missing version":  `{"service": "demo-service", "commitSHA": "` + testCommitSHA + `"}`,
+		"missing field":    `{"schemaVersion": 1, "service": "demo-service"}`,
+		"other event type": `{"schemaVersion": 1, "service": "other-service", "commitSHA": "` + testCommitSHA + `"}`,
+	} {
+		_, err := internal.DecodeEvent("backend-build demo-service", []byte(invalid))
+		require.True(t, errors.Is(err, internal.ErrInvalidEvent), name)
+	}
+}
+
+func TestLoadBackendDeployEventPayload(t *testing.T) {
+	eventPath := filepath.Join(tempDir(t), "event.json")
+	setenv(t, "GITHUB_ACTIONS", "true")
+	githubEnv := &internal.GitHubEnv{GitHubActions: "true", GitHubEventName: "repository_dispatch", GitHubEventPath: eventPath}
+
+	writeFile(t, eventPath, `{
+		"action": "backend-deploy demo-service @ dev",
+		"client_payload": {"schemaVersion": 1, "env": "dev", "service": "demo-service", "checksum": "`+testChecksum+`"},
+		"repository": {"full_name": "owner/repo"}
+	}`)
+	payload, err := internal.LoadBackendDeployEventPayload(githubEnv)
+	require.NoError(t, err)
+	require.Equal(t, "demo-service", payload.Service)
+	require.Equal(t, testChecksum, payload.Checksum)
+
+	// the payload of another event is rejected
+	writeFile(t, eventPath, `{
+		"action": "backend-build demo-service",
+		"client_payload": {"schemaVersion": 1, "service": "demo-service", "commitSHA": "`+testCommitSHA+`"}
+	}`)
+	_, err = internal.LoadBackendDeployEventPayload(githubEnv)
+	require.True(t, errors.Is(err, internal.ErrInvalidEvent))
+
+	// as are workflows not triggered by a repository_dispatch
+	_, err = internal.LoadBackendDeployEventPayload(&internal.GitHubEnv{GitHubActions: "true", GitHubEventName: "push", GitHubEventPath: eventPath})
+	require.True(t, errors.Is(err, internal.ErrInvalidEvent))
+}
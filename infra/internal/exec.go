@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// stderrTailLines is the number of trailing stderr lines included in the error of a failed command.
+const stderrTailLines = 30
+
+// lineLogger is an io.Writer logging every complete line written to it with a prefix.
+// When tail > 0 it also keeps the last tail lines.
+type lineLogger struct {
+	prefix string
+	tail   int
+
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines []string
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Write(p)
+	for {
+		i := bytes.IndexByte(l.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(l.buf.Next(i + 1))
+		l.logLine(strings.TrimRight(line, "\r\n"))
+	}
+	return len(p), nil
+}
+
+// Flush logs the last line, if it was not terminated by a new line.
+func (l *lineLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buf.Len() > 0 {
+		l.logLine(strings.TrimRight(l.buf.String(), "\r\n"))
+		l.buf.Reset()
+	}
+}
+
+func (l *lineLogger) logLine(line string) {
+	log.Print(fmt.Sprintf("%s%s", l.prefix, line))
+	if l.tail > 0 {
+		l.lines = append(l.lines, line)
+		if len(l.lines) > l.tail {
+			l.lines = l.lines[len(l.lines)-l.tail:]
+		}
+	}
+}
+
+func (l *lineLogger) Tail() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return strings.Join(l.lines, "\n")
+}
+
+// runStreaming runs the command, logging its output line by line as it is written, prefixed with "[name] ".
+// The command is killed when ctx is done. On failure the error includes the tail of stderr.
+func runStreaming(ctx context.Context, dir string, name string, args ...string) error {
+	return run(ctx, dir, nil, name, args...)
+}
+
+// runOutput is runStreaming also returning the standard output of the command.
+func runOutput(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	var output bytes.Buffer
+	err := run(ctx, dir, &output, name, args...)
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// run starts the command in its own process group, the whole group is killed when ctx is done:
+// processes spawned by the command would otherwise outlive it and keep its output open.
+func run(ctx context.Context, dir string, output io.Writer, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	log.Print(fmt.Sprintf("running command: %s", cmd.String()))
+
+	stdout := &lineLogger{prefix: fmt.Sprintf("[%s] ", name)}
+	stderr := &lineLogger{prefix: fmt.Sprintf("[%s:stderr] ", name), tail: stderrTailLines}
+	cmd.Stdout = stdout
+	if output != nil {
+		cmd.Stdout = io.MultiWriter(stdout, output)
+	}
+	cmd.Stderr = stderr
+
+	err := cmd.Start()
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				// a negative pid signals the process group
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			case <-done:
+			}
+		}()
+		err = cmd.Wait()
+		close(done)
+	}
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		reason := err.Error()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			reason = fmt.Sprintf("%s (%s)", ctxErr, reason)
+		}
+		return errors.New(fmt.Sprintf("%s failed: %s, stderr:\n%s", cmd.String(), reason, stderr.Tail()))
+	}
+	return nil
+}
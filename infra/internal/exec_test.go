@@ -0,0 +1,66 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+// captureLog returns the buffer the standard logger writes to for the duration of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestRunStreamsLines(t *testing.T) {
+	logs := captureLog(t)
+
+	output, err := internal.RunOutput(context.Background(), "", "sh", "-c", `echo one; echo two >&2; printf "three\nfour"`)
+	require.NoError(t, err)
+	require.Equal(t, "one\nthree\nfour", output)
+
+	// the trailing partial line is flushed when the command exits
+	require.Contains(t, logs.String(), "[sh] one\n")
+	require.Contains(t, logs.String(), "[sh:stderr] two\n")
+	require.Contains(t, logs.String(), "[sh] three\n")
+	require.Contains(t, logs.String(), "[sh] four\n")
+}
+
+func TestRunStderrTail(t *testing.T) {
+	captureLog(t)
+
+	err := internal.RunStreaming(context.Background(), "", "sh", "-c", `for i in $(seq 1 40); do echo "line $i" >&2; done; exit 3`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exit status 3")
+	require.Contains(t, err.Error(), "line 11\nline 12")
+	require.Contains(t, err.Error(), "line 40")
+	require.NotContains(t, err.Error(), "line 10\n", "only the last stderr lines")
+}
+
+func TestRunKillsProcessGroup(t *testing.T) {
+	captureLog(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the background sleep keeps the output open, it must be killed too
+	start := time.Now()
+	err := internal.RunStreaming(ctx, "", "sh", "-c", "sleep 30 & sleep 30")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	require.True(t, time.Since(start) < 10*time.Second, "command not killed")
+}
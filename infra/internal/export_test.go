@@ -0,0 +1,9 @@
+package internal
+
+// exported for the tests of package internal_test
+var (
+	ZipFiles     = zipFiles
+	UnzipFiles   = unzipFiles
+	RunStreaming = runStreaming
+	RunOutput    = runOutput
+)
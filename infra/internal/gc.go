@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// artifactFiles are the files stored under "<service>/<checksum>/" for every build.
+var artifactFiles = map[string]bool{
+	distZip:               true,
+	distManifest:          true,
+	distManifestSignature: true,
+}
+
+// GCPolicy decides which build artifacts are kept.
+// Artifacts referenced by deployment state (live in any environment) or by last-checksum are always kept.
+type GCPolicy struct {
+	// Number of most recent builds kept per service.
+	KeepLast int
+	// Builds deployed to any environment within this duration are kept.
+	KeepDeployedWithin time.Duration
+}
+
+// GCArtifact is the build artifacts of a service checksum.
+type GCArtifact struct {
+	Service  string
+	Checksum string
+	Keys     []string
+	Size     int64
+	BuiltAt  time.Time
+}
+
+func listArtifacts(store ArtifactStore, service string) (map[string][]*GCArtifact, error) {
+	prefix := ""
+	if service != "" {
+		prefix = service + "/"
+	}
+	infos, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := map[string]*GCArtifact{}
+	for _, info := range infos {
+		// "<service>/<checksum>/<file>"
+		if !artifactFiles[path.Base(info.Key)] {
+			continue
+		}
+		checksumDir := path.Dir(info.Key)
+		keyService := path.Dir(checksumDir)
+		if keyService == "." || (service != "" && keyService != service) {
+			continue
+		}
+
+		artifact, ok := artifacts[checksumDir]
+		if !ok {
+			artifact = &GCArtifact{Service: keyService, Checksum: path.Base(checksumDir)}
+			artifacts[checksumDir] = artifact
+		}
+		artifact.Keys = append(artifact.Keys, info.Key)
+		artifact.Size += info.Size
+		if info.LastModified.After(artifact.BuiltAt) {
+			artifact.BuiltAt = info.LastModified
+		}
+	}
+
+	byService := map[string][]*GCArtifact{}
+	for _, artifact := range artifacts {
+		sort.Strings(artifact.Keys)
+		byService[artifact.Service] = append(byService[artifact.Service], artifact)
+	}
+	for _, serviceArtifacts := range byService {
+		// newest first
+		sort.Slice(serviceArtifacts, func(i, j int) bool {
+			return serviceArtifacts[i].BuiltAt.After(serviceArtifacts[j].BuiltAt)
+		})
+	}
+	return byService, nil
+}
+
+// referencedChecksums returns the checksums of the service that must be kept whatever the policy says:
+// the last built one, the ones live in any environment and the ones deployed within keepDeployedWithin.
+func referencedChecksums(store ArtifactStore, service string, keepDeployedWithin time.Duration, now time.Time) (map[string]bool, error) {
+	bu, err := NewBuildUtils(store, service)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+	lastChecksum, err := bu.GetLastCodeChecksum()
+	if err != nil {
+		return nil, err
+	}
+	if lastChecksum != "" {
+		referenced[lastChecksum] = true
+	}
+
+	infos, err := store.List(path.Join(service, deploymentsDir) + "/")
+	if err != nil {
+		return nil, err
+	}
+	envs := map[string]bool{}
+	for _, info := range infos {
+		// "<service>/deployments/<env>/<file>"
+		envs[path.Base(path.Dir(info.Key))] = true
+	}
+
+	for env := range envs {
+		current, err := bu.GetDeployment(env)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			referenced[current.Checksum] = true
+		}
+
+		history, err := bu.GetDeploymentHistory(env)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range history {
+			if now.Sub(record.DeployedAt) <= keepDeployedWithin {
+				referenced[record.Checksum] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// GCCandidates returns the build artifacts the policy does not keep, of every service if service is empty.
+func GCCandidates(store ArtifactStore, service string, policy GCPolicy, now time.Time) ([]*GCArtifact, error) {
+	if policy.KeepLast < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid number of builds to keep: %d", policy.KeepLast))
+	}
+
+	byService, err := listArtifacts(store, service)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]string, 0, len(byService))
+	for s := range byService {
+		services = append(services, s)
+	}
+	sort.Strings(services)
+
+	var candidates []*GCArtifact
+	for _, s := range services {
+		referenced, err := referencedChecksums(store, s, policy.KeepDeployedWithin, now)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, artifact := range byService[s] {
+			if i < policy.KeepLast || referenced[artifact.Checksum] {
+				continue
+			}
+			candidates = append(candidates, artifact)
+		}
+	}
+	return candidates, nil
+}
+
+// DeleteArtifact deletes the build artifacts, the manifest first and the dist zip last:
+// an interrupted deletion never leaves a deployable artifact behind, see ArtifactDeployable.
+func DeleteArtifact(store ArtifactStore, artifact *GCArtifact) error {
+	var manifests, zips, keys []string
+	for _, key := range artifact.Keys {
+		switch path.Base(key) {
+		case distManifest:
+			manifests = append(manifests, key)
+		case distZip:
+			zips = append(zips, key)
+		default:
+			keys = append(keys, key)
+		}
+	}
+	keys = append(append(manifests, keys...), zips...)
+
+	for _, key := range keys {
+		err := store.Delete(key)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to delete %s", key))
+		}
+	}
+	return nil
+}
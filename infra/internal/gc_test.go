@@ -0,0 +1,112 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestGCCandidates(t *testing.T) {
+	dir := tempDir(t)
+	store, bu := testBuildUtils(t, dir)
+
+	now := time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC)
+	// c1 oldest ... c6 newest
+	for i, checksum := range []string{"c1", "c2", "c3", "c4", "c5", "c6"} {
+		builtAt := now.Add(time.Duration(i-10) * 24 * time.Hour)
+		for _, name := range []string{"dist.zip", "manifest.json"} {
+			key := "demo-service/" + checksum + "/" + name
+			require.NoError(t, store.Put(key, []byte("data")))
+			require.NoError(t, os.Chtimes(filepath.Join(dir, key), builtAt, builtAt))
+		}
+	}
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(nil, internal.ChecksumPointer{Checksum: "c6"}))
+	// c1 live in prod, c2 deployed to dev long ago, c3 deployed to dev recently
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "prod", Checksum: "c1", DeployedAt: now.Add(-90 * 24 * time.Hour)}))
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c2", DeployedAt: now.Add(-60 * 24 * time.Hour)}))
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c3", DeployedAt: now.Add(-5 * 24 * time.Hour)}))
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c6", DeployedAt: now}))
+
+	candidates, err := internal.GCCandidates(store, "", internal.GCPolicy{KeepLast: 1, KeepDeployedWithin: 30 * 24 * time.Hour}, now)
+	require.NoError(t, err)
+
+	var checksums []string
+	for _, artifact := range candidates {
+		checksums = append(checksums, artifact.Checksum)
+		require.Equal(t, int64(8), artifact.Size)
+	}
+	require.Equal(t, []string{"c5", "c4", "c2"}, checksums)
+
+	for _, artifact := range candidates {
+		require.NoError(t, internal.DeleteArtifact(store, artifact))
+	}
+	for _, checksum := range []string{"c1", "c3", "c6"} {
+		exists, err := bu.ArtifactDeployable(checksum, "dev", &internal.SigningConfig{})
+		require.NoError(t, err)
+		require.True(t, exists)
+	}
+	for _, checksum := range checksums {
+		exists, err := bu.ArtifactDeployable(checksum, "dev", &internal.SigningConfig{})
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+
+	// live and last built checksums are always kept
+	candidates, err = internal.GCCandidates(store, "demo-service", internal.GCPolicy{KeepLast: 0}, now)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "c3", candidates[0].Checksum)
+}
+
+// failingDeleteStore fails every delete after the first `deletes`.
+type failingDeleteStore struct {
+	*internal.LocalArtifactStore
+	deletes int
+}
+
+func (s *failingDeleteStore) Delete(key string) error {
+	if s.deletes == 0 {
+		return errors.New("delete failed")
+	}
+	s.deletes--
+	return s.LocalArtifactStore.Delete(key)
+}
+
+func TestDeleteArtifactInterrupted(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+	for _, name := range []string{"dist.zip", "manifest.json", "manifest.json.sig"} {
+		require.NoError(t, store.Put("demo-service/c1/"+name, []byte("data")))
+	}
+	config := &internal.SigningConfig{ProtectedEnvs: []string{"prod"}}
+
+	deployable, err := bu.ArtifactDeployable("c1", "prod", config)
+	require.NoError(t, err)
+	require.True(t, deployable)
+
+	candidates, err := internal.GCCandidates(store, "demo-service", internal.GCPolicy{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Error(t, internal.DeleteArtifact(&failingDeleteStore{LocalArtifactStore: store, deletes: 1}, candidates[0]))
+
+	// the dist zip is left, but not the manifest
+	exists, err := store.Exists("demo-service/c1/dist.zip")
+	require.NoError(t, err)
+	require.True(t, exists)
+	for _, env := range []string{"dev", "prod"} {
+		deployable, err := bu.ArtifactDeployable("c1", env, config)
+		require.NoError(t, err)
+		require.False(t, deployable, env)
+	}
+
+	// and collected by the next gc
+	candidates, err = internal.GCCandidates(store, "demo-service", internal.GCPolicy{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.NoError(t, internal.DeleteArtifact(store, candidates[0]))
+}
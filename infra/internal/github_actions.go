@@ -1,51 +1,88 @@
 package internal
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/pkg/errors"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
+// GitHubEnv is the default env of GitHub Actions workflows, every variable is optional when not running on GitHub Actions.
 type GitHubEnv struct {
 	// Always set to true.
-	Ci string `envconfig:"CI" required:"true"`
+	Ci string `envconfig:"CI"`
 	// The path to the GitHub home directory used to store user data. For example, /github/home.
-	Home string `envconfig:"HOME" required:"true"`
+	Home string `envconfig:"HOME"`
 	// The name of the workflow.
-	GitHubWorkflow string `envconfig:"GITHUB_WORKFLOW" required:"true"`
+	GitHubWorkflow string `envconfig:"GITHUB_WORKFLOW"`
 	// A unique number for each run within a repository. This number does not change if you re-run the workflow run.
-	GitHubRunID string `envconfig:"GITHUB_RUN_ID" required:"true"`
+	GitHubRunID string `envconfig:"GITHUB_RUN_ID"`
 	// A unique number for each run of a particular workflow in a repository. This number begins at 1 for the workflow's first run, and increments with each new run. This number does not change if you re-run the workflow run.
-	GitHubRunNumber string `envconfig:"GITHUB_RUN_NUMBER" required:"true"`
+	GitHubRunNumber string `envconfig:"GITHUB_RUN_NUMBER"`
 	// The unique identifier (id) of the action.
-	GitHubAction string `envconfig:"GITHUB_ACTION" required:"true"`
+	GitHubAction string `envconfig:"GITHUB_ACTION"`
 	// Always set to true when GitHub Actions is running the workflow. You can use this variable to differentiate when tests are being run locally or by GitHub Actions.
-	GitHubActions string `envconfig:"GITHUB_ACTIONS" required:"true"`
+	GitHubActions string `envconfig:"GITHUB_ACTIONS"`
 	// The name of the person or app that initiated the workflow. For example, octocat.
-	GitHubActor string `envconfig:"GITHUB_ACTOR" required:"true"`
+	GitHubActor string `envconfig:"GITHUB_ACTOR"`
 	// The owner and repository name. For example, octocat/Hello-World.
-	GitHubRepository string `envconfig:"GITHUB_REPOSITORY" required:"true"`
+	GitHubRepository string `envconfig:"GITHUB_REPOSITORY"`
 	// The name of the webhook event that triggered the workflow.
-	GitHubEventName string `envconfig:"GITHUB_EVENT_NAME" required:"true"`
+	GitHubEventName string `envconfig:"GITHUB_EVENT_NAME"`
 	// The path of the file with the complete webhook event payload. For example, /github/workflow/event.json.
-	GitHubEventPath string `envconfig:"GITHUB_EVENT_PATH" required:"true"`
+	GitHubEventPath string `envconfig:"GITHUB_EVENT_PATH"`
 	// The GitHub workspace directory path. The workspace directory contains a subdirectory with a copy of your repository if your workflow uses the actions/checkout action. If you don't use the actions/checkout action, the directory will be empty. For example, /home/runner/work/my-repo-name/my-repo-name.
-	GitHubWorkspace string `envconfig:"GITHUB_WORKSPACE" required:"true"`
+	GitHubWorkspace string `envconfig:"GITHUB_WORKSPACE"`
 	// The commit SHA that triggered the workflow. For example, ffac537e6cbbf934b08745a378932722df287a53.
-	GitHubSha string `envconfig:"GITHUB_SHA" required:"true"`
+	GitHubSha string `envconfig:"GITHUB_SHA"`
 	// The branch or tag ref that triggered the workflow. For example, refs/heads/feature-branch-1. If neither a branch or tag is available for the event type, the variable will not exist.
-	GitHubRef string `envconfig:"GITHUB_REF" required:"true"`
+	GitHubRef string `envconfig:"GITHUB_REF"`
 	// Only set for forked repositories. The branch of the head repository.
-	GitHubHeadRef string `envconfig:"GITHUB_HEAD_REF" required:"false"`
+	GitHubHeadRef string `envconfig:"GITHUB_HEAD_REF"`
 	// Only set for forked repositories. The branch of the base repository.
-	GitHubBaseRef string `envconfig:"GITHUB_BASE_REF" required:"false"`
+	GitHubBaseRef string `envconfig:"GITHUB_BASE_REF"`
+	// The URL of the GitHub server. For example, https://github.com.
+	GitHubServerURL string `envconfig:"GITHUB_SERVER_URL" default:"https://github.com"`
 }
 
+// RunURL is the url of the logs of the workflow run, empty when not run by a workflow.
+func (e *GitHubEnv) RunURL() string {
+	if e.GitHubRunID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", e.GitHubServerURL, e.GitHubRepository, e.GitHubRunID)
+}
+
+// LoadGitHubEnv loads the GitHub Actions env variables. On GitHub Actions, the ones the workflow commands rely on are required.
+// Elsewhere, e.g. when a workflow command is run locally, the variables set are loaded and the others left empty.
 func LoadGitHubEnv() (*GitHubEnv, error) {
 	env := GitHubEnv{}
 	err := envconfig.Process("", &env)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load github actions env variables")
 	}
+	if !RunningOnGitHubActions() {
+		return &env, nil
+	}
+
+	for _, required := range []struct{ key, value string }{
+		{"GITHUB_WORKFLOW", env.GitHubWorkflow},
+		{"GITHUB_RUN_ID", env.GitHubRunID},
+		{"GITHUB_ACTOR", env.GitHubActor},
+		{"GITHUB_REPOSITORY", env.GitHubRepository},
+		{"GITHUB_EVENT_NAME", env.GitHubEventName},
+		{"GITHUB_EVENT_PATH", env.GitHubEventPath},
+	} {
+		if required.value == "" {
+			return nil, errors.New(fmt.Sprintf("failed to load github actions env variables: required key %s missing value", required.key))
+		}
+	}
 	return &env, nil
 }
+
+// RunningOnGitHubActions reports whether the command is run by a GitHub Actions workflow.
+func RunningOnGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
@@ -0,0 +1,43 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestLoadGitHubEnv(t *testing.T) {
+	for _, key := range []string{
+		"GITHUB_ACTIONS", "GITHUB_WORKFLOW", "GITHUB_RUN_ID", "GITHUB_ACTOR", "GITHUB_EVENT_NAME", "GITHUB_EVENT_PATH", "GITHUB_SERVER_URL",
+	} {
+		setenv(t, key, "")
+	}
+	setenv(t, "GITHUB_REPOSITORY", "owner/repo")
+
+	// run locally, nothing is required
+	env, err := internal.LoadGitHubEnv()
+	require.NoError(t, err)
+	require.Equal(t, "owner/repo", env.GitHubRepository)
+	require.Equal(t, "https://github.com", env.GitHubServerURL)
+	require.Empty(t, env.RunURL())
+
+	// on GitHub Actions, the run and its event are
+	setenv(t, "GITHUB_ACTIONS", "true")
+	_, err = internal.LoadGitHubEnv()
+	require.Error(t, err)
+
+	for key, value := range map[string]string{
+		"GITHUB_WORKFLOW":   "test",
+		"GITHUB_RUN_ID":     "1",
+		"GITHUB_ACTOR":      "octocat",
+		"GITHUB_EVENT_NAME": "repository_dispatch",
+		"GITHUB_EVENT_PATH": "event.json",
+	} {
+		setenv(t, key, value)
+	}
+	env, err = internal.LoadGitHubEnv()
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/owner/repo/actions/runs/1", env.RunURL())
+}
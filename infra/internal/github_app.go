@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// GitHub rejects app JWTs valid for more than 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+	// backdate app JWTs "iat" against clock drift.
+	appJWTClockDrift = time.Minute
+	// installation tokens are refreshed this long before they expire.
+	installationTokenRefreshMargin = 5 * time.Minute
+)
+
+// ParseGitHubAppPrivateKey parses the PEM private key of a GitHub App, as downloaded or base64 encoded.
+func ParseGitHubAppPrivateKey(value string) (*rsa.PrivateKey, error) {
+	data := []byte(value)
+	if !strings.Contains(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.Wrap(err, "github app private key is neither PEM nor base64 encoded PEM")
+		}
+		data = decoded
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("github app private key: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse github app private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("github app private key: expected an RSA key, got %T", key))
+	}
+	return rsaKey, nil
+}
+
+// appJWTSource mints the RS256 JWTs a GitHub App authenticates as itself with.
+type appJWTSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	expiresAt := now.Add(appJWTLifetime)
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal jwt header")
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-appJWTClockDrift).Unix(),
+		"exp": expiresAt.Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal jwt claims")
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign github app jwt")
+	}
+
+	return &oauth2.Token{
+		AccessToken: unsigned + "." + base64.RawURLEncoding.EncodeToString(signature),
+		TokenType:   "Bearer",
+		Expiry:      expiresAt.Add(-appJWTClockDrift),
+	}, nil
+}
+
+// installationTransport authenticates requests with an installation access token, minted as the app when
+// missing or about to expire. The token is minted once per request, with its context: a failure fails the
+// request, which is retried by GitHubClient.do.
+type installationTransport struct {
+	app            *github.Client
+	installationID int64
+	base           http.RoundTripper
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.installationToken(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	// a RoundTripper must not modify the request
+	authReq := req.Clone(req.Context())
+	token.SetAuthHeader(authReq)
+	return t.base.RoundTrip(authReq)
+}
+
+func (t *installationTransport) installationToken(ctx context.Context) (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token.Valid() {
+		return t.token, nil
+	}
+	token, _, err := t.app.Apps.CreateInstallationToken(ctx, t.installationID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create installation token")
+	}
+
+	expiry := token.GetExpiresAt()
+	if !expiry.IsZero() {
+		expiry = expiry.Add(-installationTokenRefreshMargin)
+	}
+	t.token = &oauth2.Token{AccessToken: token.GetToken(), TokenType: "token", Expiry: expiry}
+	return t.token, nil
+}
+
+// githubHTTPClient authenticates with the GitHub credentials picked from the secrets:
+// a GitHub App installation if its secrets are set, the personal access token otherwise.
+func githubHTTPClient(secrets *Secrets, config *GitHubConfig) (*http.Client, error) {
+	appSecrets := 0
+	for _, set := range []bool{secrets.GitHubAppID != 0, secrets.GitHubAppInstallationID != 0, secrets.GitHubAppPrivateKey != ""} {
+		if set {
+			appSecrets++
+		}
+	}
+
+	switch {
+	case appSecrets == 3:
+		key, err := ParseGitHubAppPrivateKey(secrets.GitHubAppPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		jwt := oauth2.ReuseTokenSource(nil, &appJWTSource{appID: secrets.GitHubAppID, key: key})
+		client, err := newGitHubAPIClient(oauth2.NewClient(context.Background(), jwt), config)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: &installationTransport{
+			app:            client,
+			installationID: secrets.GitHubAppInstallationID,
+			base:           http.DefaultTransport,
+		}}, nil
+	case appSecrets > 0:
+		return nil, errors.New("incomplete github app secrets, GITHUB_APP_ID, GITHUB_APP_INSTALLATION_ID and GITHUB_APP_PRIVATE_KEY are all required")
+	case secrets.PersonalAccessToken != "":
+		return oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: secrets.PersonalAccessToken})), nil
+	default:
+		return nil, errors.New("no github credentials, set PERSONAL_ACCESS_TOKEN or the GITHUB_APP_* secrets")
+	}
+}
@@ -0,0 +1,48 @@
+package internal_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestParseGitHubAppPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pkcs8 := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes}))
+
+	for _, value := range []string{pkcs1, pkcs8, base64.StdEncoding.EncodeToString([]byte(pkcs1))} {
+		parsed, err := internal.ParseGitHubAppPrivateKey(value)
+		require.NoError(t, err)
+		require.Equal(t, key.D, parsed.D)
+	}
+
+	_, err = internal.ParseGitHubAppPrivateKey("not a key")
+	require.Error(t, err)
+}
+
+func TestNewGitHubClientCredentials(t *testing.T) {
+	config := &internal.GitHubConfig{RetryMaxAttempts: 1}
+
+	_, err := internal.NewGitHubClient("owner/repo", &internal.Secrets{PersonalAccessToken: "token"}, config)
+	require.NoError(t, err)
+
+	// no credentials at all
+	_, err = internal.NewGitHubClient("owner/repo", &internal.Secrets{}, config)
+	require.Error(t, err)
+
+	// partial app secrets are not silently ignored
+	_, err = internal.NewGitHubClient("owner/repo", &internal.Secrets{PersonalAccessToken: "token", GitHubAppID: 1}, config)
+	require.Error(t, err)
+}
@@ -4,36 +4,203 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
 
 	"github.com/google/go-github/v32/github"
-	"golang.org/x/oauth2"
 )
 
+var (
+	// ErrGitHubAuth is returned when GitHub rejects the credentials, or their permissions. Not retried.
+	ErrGitHubAuth = errors.New("github authentication failed")
+	// ErrGitHubRetriesExhausted is returned when a request still fails with a retryable error after the last attempt.
+	ErrGitHubRetriesExhausted = errors.New("github request retries exhausted")
+)
+
+type GitHubConfig struct {
+	// GitHub Enterprise Server API url, e.g. https://github.example.com/api/v3/, api.github.com if empty.
+	BaseURL string `envconfig:"GITHUB_BASE_URL" required:"false"`
+	// GitHub Enterprise Server uploads url, BaseURL if empty.
+	UploadURL string `envconfig:"GITHUB_UPLOAD_URL" required:"false"`
+	// Number of attempts of every GitHub API request, 1 disables retries.
+	RetryMaxAttempts int `envconfig:"GITHUB_RETRY_MAX_ATTEMPTS" default:"5"`
+	// Delay before the first retry, doubled on every attempt.
+	RetryBaseDelay time.Duration `envconfig:"GITHUB_RETRY_BASE_DELAY" default:"1s"`
+	// Longest delay between two attempts, including rate limit waits.
+	RetryMaxDelay time.Duration `envconfig:"GITHUB_RETRY_MAX_DELAY" default:"1m"`
+}
+
+func LoadGitHubConfig() (*GitHubConfig, error) {
+	config := GitHubConfig{}
+	err := envconfig.Process("", &config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load github config")
+	}
+	return &config, nil
+}
+
 type GitHubClient struct {
 	owner  string
 	repo   string
-	client github.Client
+	client *github.Client
+	config GitHubConfig
 }
 
-func NewGitHubClient(githubOwnerRepo, accessToken string) (*GitHubClient, error) {
+// NewGitHubClient authenticates with the credentials found in the secrets, see githubHTTPClient.
+func NewGitHubClient(githubOwnerRepo string, secrets *Secrets, config *GitHubConfig) (*GitHubClient, error) {
 	ownerRepoArr := strings.Split(githubOwnerRepo, "/")
 	if len(ownerRepoArr) != 2 {
 		return nil, errors.New(fmt.Sprintf("invalid githubOwnerRepo: %s, couldn't be split", githubOwnerRepo))
 	}
 
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: accessToken})
-	tc := oauth2.NewClient(context.Background(), ts)
+	if config.RetryMaxAttempts < 1 {
+		return nil, errors.New(fmt.Sprintf("invalid github retry max attempts: %d", config.RetryMaxAttempts))
+	}
+
+	httpClient, err := githubHTTPClient(secrets, config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newGitHubAPIClient(httpClient, config)
+	if err != nil {
+		return nil, err
+	}
 
 	return &GitHubClient{
 		owner:  ownerRepoArr[0],
 		repo:   ownerRepoArr[1],
-		client: *github.NewClient(tc),
+		client: client,
+		config: *config,
 	}, nil
 }
 
+// newGitHubAPIClient targets api.github.com, or the GitHub Enterprise Server configured.
+func newGitHubAPIClient(httpClient *http.Client, config *GitHubConfig) (*github.Client, error) {
+	if config.BaseURL == "" {
+		if config.UploadURL != "" {
+			return nil, errors.New("github upload url set without a base url")
+		}
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := config.UploadURL
+	if uploadURL == "" {
+		uploadURL = config.BaseURL
+	}
+	client, err := github.NewEnterpriseClient(config.BaseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid github base url: %s or upload url: %s", config.BaseURL, uploadURL))
+	}
+	return client, nil
+}
+
+// retryAfterHeader returns the delay requested by the "Retry-After" response header, 0 if none.
+func retryAfterHeader(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// backoff returns the exponential delay before the attempt+1 attempt, with jitter.
+func (c *GitHubClient) backoff(attempt int) time.Duration {
+	delay := c.config.RetryBaseDelay
+	for i := 1; i < attempt && delay < c.config.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.config.RetryMaxDelay {
+		delay = c.config.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// between half and the full delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryDelay classifies a request error, returns whether it is worth retrying and after how long.
+func (c *GitHubClient) retryDelay(err error, attempt int) (bool, time.Duration, error) {
+	cause := errors.Cause(err)
+	// failures of the transport, e.g. minting an installation token, are returned in a *url.Error
+	if urlErr, ok := cause.(*url.Error); ok {
+		cause = errors.Cause(urlErr.Err)
+	}
+	switch e := cause.(type) {
+	case *github.RateLimitError:
+		wait := time.Until(e.Rate.Reset.Time) + time.Second
+		if wait > c.config.RetryMaxDelay {
+			return false, 0, errors.Wrap(err, fmt.Sprintf("rate limited until %s", e.Rate.Reset.Time))
+		}
+		return true, wait, err
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return true, *e.RetryAfter, err
+		}
+		return true, c.backoff(attempt), err
+	case *github.TwoFactorAuthError:
+		return false, 0, errors.Wrap(ErrGitHubAuth, err.Error())
+	case *github.ErrorResponse:
+		status := e.Response.StatusCode
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return false, 0, errors.Wrap(ErrGitHubAuth, err.Error())
+		case status == http.StatusTooManyRequests || status >= 500:
+			if wait := retryAfterHeader(e.Response); wait > 0 {
+				return true, wait, err
+			}
+			return true, c.backoff(attempt), err
+		default:
+			return false, 0, err
+		}
+	default:
+		// network errors, timeouts...
+		return true, c.backoff(attempt), err
+	}
+}
+
+// do calls request until it succeeds, fails with an error not worth retrying or the last attempt fails.
+func (c *GitHubClient) do(ctx context.Context, name string, request func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := request()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(err, fmt.Sprintf("%s req/resp error", name))
+		}
+
+		retryable, wait, err := c.retryDelay(err, attempt)
+		if !retryable {
+			return errors.Wrap(err, fmt.Sprintf("%s req/resp error", name))
+		}
+		if attempt >= c.config.RetryMaxAttempts {
+			return errors.Wrap(ErrGitHubRetriesExhausted, fmt.Sprintf("%s req/resp error after %d attempt(s): %s", name, attempt, err))
+		}
+		if wait > c.config.RetryMaxDelay {
+			wait = c.config.RetryMaxDelay
+		}
+
+		log.Print(fmt.Sprintf("%s attempt %d failed, retrying in %s: %s", name, attempt, wait, err))
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), fmt.Sprintf("%s req/resp error", name))
+		case <-time.After(wait):
+		}
+	}
+}
+
 func (c *GitHubClient) RepositoryDispatch(ctx context.Context, eventType string, eventPayload interface{}) error {
 	payloadBytes, err := json.Marshal(eventPayload)
 	if err != nil {
@@ -46,10 +213,17 @@ func (c *GitHubClient) RepositoryDispatch(ctx context.Context, eventType string,
 		ClientPayload: &jsonRawMessage,
 	}
 
-	_, _, err = c.client.Repositories.Dispatch(ctx, c.owner, c.repo, req)
+	return c.do(ctx, "repository dispatch", func() error {
+		_, _, err := c.client.Repositories.Dispatch(ctx, c.owner, c.repo, req)
+		return err
+	})
+}
+
+// DispatchEvent validates the event payload and dispatches it, see EncodeEvent.
+func (c *GitHubClient) DispatchEvent(ctx context.Context, eventPayload EventPayload) error {
+	eventType, payloadBytes, err := EncodeEvent(eventPayload)
 	if err != nil {
-		return errors.Wrap(err, "repository dispatch req/resp error")
+		return err
 	}
-
-	return nil
+	return c.RepositoryDispatch(ctx, eventType, json.RawMessage(payloadBytes))
 }
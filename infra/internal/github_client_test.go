@@ -0,0 +1,214 @@
+package internal_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+	"infra/internal/githubtest"
+)
+
+func testGitHubConfig(server *githubtest.Server) *internal.GitHubConfig {
+	return &internal.GitHubConfig{
+		BaseURL:          server.BaseURL(),
+		RetryMaxAttempts: 3,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    10 * time.Millisecond,
+	}
+}
+
+func TestGitHubClientRepositoryDispatch(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+
+	client, err := internal.NewGitHubClient("owner/repo", &internal.Secrets{PersonalAccessToken: "token"}, testGitHubConfig(server))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// server errors are retried
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusBadGateway, Times: 1})
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{"service": "demo-service"}))
+	require.Len(t, server.Requests(), 2)
+	require.Equal(t, "Bearer token", server.Requests()[0].Header.Get("Authorization"))
+	dispatches := server.Dispatches()
+	require.Len(t, dispatches, 1)
+	require.Equal(t, "event", dispatches[0].EventType)
+	require.JSONEq(t, `{"service": "demo-service"}`, string(dispatches[0].ClientPayload))
+
+	// until the last attempt
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusServiceUnavailable, Times: 3})
+	err = client.RepositoryDispatch(ctx, "event", map[string]string{})
+	require.True(t, errors.Is(err, internal.ErrGitHubRetriesExhausted))
+	require.Len(t, server.Requests(), 5)
+
+	// auth errors are not
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusUnauthorized})
+	err = client.RepositoryDispatch(ctx, "event", map[string]string{})
+	require.True(t, errors.Is(err, internal.ErrGitHubAuth))
+	require.Len(t, server.Requests(), 6)
+	require.Len(t, server.Dispatches(), 1)
+}
+
+func TestGitHubClientDispatchNestedService(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+
+	client, err := internal.NewGitHubClient("owner/repo", &internal.Secrets{PersonalAccessToken: "token"}, testGitHubConfig(server))
+	require.NoError(t, err)
+
+	payload := &internal.BackendBuildEventPayload{Service: "group/beta-service", CommitSHA: testCommitSHA}
+	require.NoError(t, client.DispatchEvent(context.Background(), payload))
+	dispatches := server.Dispatches()
+	require.Len(t, dispatches, 1)
+	require.Equal(t, "backend-build group/beta-service", dispatches[0].EventType)
+
+	decoded, err := internal.DecodeEvent(dispatches[0].EventType, dispatches[0].ClientPayload)
+	require.NoError(t, err)
+	require.Equal(t, "group/beta-service", decoded.(*internal.BackendBuildEventPayload).Service)
+}
+
+func TestGitHubClientRetryDelay(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+
+	config := testGitHubConfig(server)
+	config.RetryMaxDelay = 3 * time.Second
+	client, err := internal.NewGitHubClient("owner/repo", &internal.Secrets{PersonalAccessToken: "token"}, config)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Retry-After is honored
+	server.Fail(githubtest.Failure{
+		Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusServiceUnavailable, Times: 1,
+		Header: http.Header{"Retry-After": {"1"}},
+	})
+	start := time.Now()
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+	require.True(t, time.Since(start) >= time.Second)
+	require.Len(t, server.Requests(), 2)
+
+	// so is the Retry-After of abuse rate limits
+	abuse := githubtest.Failure{
+		Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusForbidden, Times: 1,
+		Header:           http.Header{"Retry-After": {"1"}},
+		DocumentationURL: "https://developer.github.com/v3/#abuse-rate-limits",
+	}
+	server.Fail(abuse)
+	start = time.Now()
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+	require.True(t, time.Since(start) >= time.Second)
+	require.Len(t, server.Requests(), 4)
+
+	// which are retried with backoff without it
+	abuse.Header = nil
+	server.Fail(abuse)
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+	require.Len(t, server.Requests(), 6)
+
+	// rate limits are waited for until their reset
+	server.Fail(githubtest.Failure{
+		Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusForbidden, Times: 1,
+		Header: http.Header{
+			"X-Ratelimit-Limit":     {"5000"},
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+	require.Len(t, server.Requests(), 8)
+
+	// unless the reset is too far away
+	server.Fail(githubtest.Failure{
+		Method: http.MethodPost, Path: "/dispatches$", Status: http.StatusForbidden, Times: 1,
+		Header: http.Header{
+			"X-Ratelimit-Limit":     {"5000"},
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+		},
+	})
+	err = client.RepositoryDispatch(ctx, "event", map[string]string{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rate limited until")
+	require.False(t, errors.Is(err, internal.ErrGitHubAuth))
+	require.Len(t, server.Requests(), 9)
+	require.Len(t, server.Dispatches(), 4)
+}
+
+func testGitHubAppSecrets(t *testing.T) *internal.Secrets {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return &internal.Secrets{
+		GitHubAppID:             42,
+		GitHubAppInstallationID: 7,
+		GitHubAppPrivateKey:     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	}
+}
+
+func TestGitHubClientAppInstallation(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+
+	client, err := internal.NewGitHubClient("owner/repo", testGitHubAppSecrets(t), testGitHubConfig(server))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+	require.NoError(t, client.RepositoryDispatch(ctx, "event", map[string]string{}))
+
+	// the installation token is minted once, with the app jwt, and reused
+	tokens := server.InstallationTokens()
+	require.Len(t, tokens, 1)
+	require.Equal(t, int64(7), tokens[0].InstallationID)
+
+	requests := server.Requests()
+	require.Len(t, requests, 3)
+	require.Equal(t, "/app/installations/7/access_tokens", requests[0].Path)
+	require.True(t, strings.HasPrefix(requests[0].Header.Get("Authorization"), "Bearer ey"))
+	for _, r := range requests[1:] {
+		require.Equal(t, "/repos/owner/repo/dispatches", r.Path)
+		require.Equal(t, "token "+tokens[0].Token, r.Header.Get("Authorization"))
+	}
+}
+
+func TestGitHubClientAppInstallationTokenErrors(t *testing.T) {
+	server := githubtest.NewServer()
+	defer server.Close()
+
+	client, err := internal.NewGitHubClient("owner/repo", testGitHubAppSecrets(t), testGitHubConfig(server))
+	require.NoError(t, err)
+
+	// minting is retried by the request, once per attempt
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/access_tokens$", Status: http.StatusBadGateway})
+	err = client.RepositoryDispatch(context.Background(), "event", map[string]string{})
+	require.True(t, errors.Is(err, internal.ErrGitHubRetriesExhausted))
+	require.Len(t, server.Requests(), 3)
+
+	// rejected app credentials are not retried
+	server = githubtest.NewServer()
+	defer server.Close()
+	client, err = internal.NewGitHubClient("owner/repo", testGitHubAppSecrets(t), testGitHubConfig(server))
+	require.NoError(t, err)
+	server.Fail(githubtest.Failure{Method: http.MethodPost, Path: "/access_tokens$", Status: http.StatusUnauthorized})
+	err = client.RepositoryDispatch(context.Background(), "event", map[string]string{})
+	require.True(t, errors.Is(err, internal.ErrGitHubAuth))
+	require.Len(t, server.Requests(), 1)
+
+	// nor is a cancelled request, the token is not minted for it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.RepositoryDispatch(ctx, "event", map[string]string{})
+	require.Error(t, err)
+	require.Len(t, server.Requests(), 1)
+	require.Empty(t, server.Dispatches())
+}
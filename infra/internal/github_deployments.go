@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+)
+
+// GitHub deployment states reported by the deploy command.
+const (
+	GitHubDeploymentInProgress = "in_progress"
+	GitHubDeploymentSuccess    = "success"
+	GitHubDeploymentFailure    = "failure"
+)
+
+// githubDeploymentPayload is attached to the GitHub deployments, identifying the deployed artifact.
+type githubDeploymentPayload struct {
+	Service  string `json:"service"`
+	Checksum string `json:"checksum"`
+}
+
+// CreateDeployment creates the GitHub deployment of the deploy event, of its commit to its environment.
+// Returns the id of the deployment, its statuses are posted with CreateDeploymentStatus.
+func (c *GitHubClient) CreateDeployment(ctx context.Context, eventPayload *BackendDeployEventPayload) (int64, error) {
+	if eventPayload.CommitSHA == "" {
+		return 0, errors.New(fmt.Sprintf("no commit sha to create the %s deployment of %s for", eventPayload.Env, eventPayload.Service))
+	}
+
+	req := github.DeploymentRequest{
+		Ref:         github.String(eventPayload.CommitSHA),
+		Task:        github.String("deploy"),
+		Environment: github.String(eventPayload.Env),
+		Description: github.String(fmt.Sprintf("%s %s", eventPayload.Service, eventPayload.Checksum)),
+		Payload:     githubDeploymentPayload{Service: eventPayload.Service, Checksum: eventPayload.Checksum},
+		// the artifact was built already, deploy the commit as is
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+	}
+
+	var deployment *github.Deployment
+	err := c.do(ctx, "create deployment", func() error {
+		var err error
+		deployment, _, err = c.client.Repositories.CreateDeployment(ctx, c.owner, c.repo, &req)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deployment.GetID(), nil
+}
+
+// GitHubDeploymentStatus is a state change of a GitHub deployment.
+type GitHubDeploymentStatus struct {
+	// One of GitHubDeploymentInProgress, GitHubDeploymentSuccess or GitHubDeploymentFailure.
+	State       string
+	Description string
+	// Url of the deployed service, shown on the repository environments page.
+	EnvironmentURL string
+	// Url of the deploy logs, e.g. the workflow run.
+	LogURL string
+}
+
+func (c *GitHubClient) CreateDeploymentStatus(ctx context.Context, deploymentID int64, status GitHubDeploymentStatus) error {
+	req := github.DeploymentStatusRequest{State: github.String(status.State)}
+	if status.Description != "" {
+		req.Description = github.String(status.Description)
+	}
+	if status.EnvironmentURL != "" {
+		req.EnvironmentURL = github.String(status.EnvironmentURL)
+	}
+	if status.LogURL != "" {
+		req.LogURL = github.String(status.LogURL)
+	}
+
+	return c.do(ctx, "create deployment status", func() error {
+		_, _, err := c.client.Repositories.CreateDeploymentStatus(ctx, c.owner, c.repo, deploymentID, &req)
+		return err
+	})
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// GitHub commit status states.
+const (
+	CommitStatusPending = "pending"
+	CommitStatusSuccess = "success"
+	CommitStatusFailure = "failure"
+	CommitStatusError   = "error"
+)
+
+// CommitStatus is the pipeline progress of a service, shown on its commit.
+type CommitStatus struct {
+	// One of CommitStatusPending, CommitStatusSuccess, CommitStatusFailure or CommitStatusError.
+	State       string
+	Description string
+	// Url of the logs, e.g. the workflow run.
+	TargetURL string
+}
+
+// CommitStatusContext is the context the commit statuses of the service are posted under, each one replacing the previous.
+func CommitStatusContext(service string) string {
+	return fmt.Sprintf("infra/%s", service)
+}
+
+// CreateCommitStatus posts the status of the service on the commit.
+func (c *GitHubClient) CreateCommitStatus(ctx context.Context, commitSHA string, service string, status CommitStatus) error {
+	req := github.RepoStatus{
+		State:   github.String(status.State),
+		Context: github.String(CommitStatusContext(service)),
+	}
+	if status.Description != "" {
+		req.Description = github.String(status.Description)
+	}
+	if status.TargetURL != "" {
+		req.TargetURL = github.String(status.TargetURL)
+	}
+
+	return c.do(ctx, "create commit status", func() error {
+		_, _, err := c.client.Repositories.CreateStatus(ctx, c.owner, c.repo, commitSHA, &req)
+		return err
+	})
+}
@@ -0,0 +1,295 @@
+// Package githubtest provides an in-process fake GitHub API server,
+// for tests of internal.GitHubClient and of the commands using it.
+package githubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiPrefix is the path prefix of the GitHub Enterprise Server API, see github.NewEnterpriseClient.
+const apiPrefix = "/api/v3"
+
+var (
+	dispatchesPath         = regexp.MustCompile(`^/repos/[^/]+/[^/]+/dispatches$`)
+	commitStatusesPath     = regexp.MustCompile(`^/repos/[^/]+/[^/]+/statuses/([^/]+)$`)
+	deploymentsPath        = regexp.MustCompile(`^/repos/[^/]+/[^/]+/deployments$`)
+	deploymentStatusesPath = regexp.MustCompile(`^/repos/[^/]+/[^/]+/deployments/(\d+)/statuses$`)
+	installationTokenPath  = regexp.MustCompile(`^/app/installations/(\d+)/access_tokens$`)
+)
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	// Path without the API prefix, e.g. "/repos/owner/repo/dispatches".
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+type Dispatch struct {
+	EventType     string          `json:"event_type"`
+	ClientPayload json.RawMessage `json:"client_payload"`
+}
+
+type CommitStatus struct {
+	SHA         string `json:"-"`
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+type Deployment struct {
+	ID               int64           `json:"id"`
+	Ref              string          `json:"ref"`
+	Task             string          `json:"task"`
+	Environment      string          `json:"environment"`
+	Description      string          `json:"description"`
+	Payload          json.RawMessage `json:"payload"`
+	AutoMerge        *bool           `json:"auto_merge"`
+	RequiredContexts []string        `json:"required_contexts"`
+}
+
+type DeploymentStatus struct {
+	DeploymentID   int64  `json:"-"`
+	State          string `json:"state"`
+	Description    string `json:"description"`
+	EnvironmentURL string `json:"environment_url"`
+	LogURL         string `json:"log_url"`
+}
+
+type InstallationToken struct {
+	InstallationID int64
+	Token          string
+}
+
+// Failure makes the server answer the matching requests with an error instead of handling them.
+type Failure struct {
+	Method string
+	// Regular expression matched against the request path, without the API prefix.
+	Path string
+	// Response status code, e.g. http.StatusBadGateway.
+	Status int
+	// Extra response headers, e.g. "Retry-After".
+	Header http.Header
+	// "documentation_url" of the error response, e.g. the abuse rate limits documentation.
+	DocumentationURL string
+	// Number of matching requests failed, every one if 0.
+	Times int
+
+	path *regexp.Regexp
+}
+
+// Server is a fake GitHub API, serving the endpoints used by internal.GitHubClient.
+// Every request is recorded, successful ones are also decoded, see Dispatches, CommitStatuses...
+type Server struct {
+	*httptest.Server
+
+	mu                 sync.Mutex
+	nextID             int64
+	failures           []*Failure
+	requests           []Request
+	dispatches         []Dispatch
+	commitStatuses     []CommitStatus
+	deployments        []Deployment
+	deploymentStatuses []DeploymentStatus
+	installationTokens []InstallationToken
+}
+
+// NewServer starts a server, to be closed by the caller.
+func NewServer() *Server {
+	s := &Server{nextID: 1}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL is the API url the client is configured with, e.g. GITHUB_BASE_URL.
+func (s *Server) BaseURL() string {
+	return s.URL + apiPrefix + "/"
+}
+
+// Fail injects a failure, failures are matched in the order they were injected.
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failure.path = regexp.MustCompile(failure.Path)
+	s.failures = append(s.failures, &failure)
+}
+
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) Dispatches() []Dispatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Dispatch(nil), s.dispatches...)
+}
+
+func (s *Server) CommitStatuses() []CommitStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CommitStatus(nil), s.commitStatuses...)
+}
+
+func (s *Server) Deployments() []Deployment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Deployment(nil), s.deployments...)
+}
+
+func (s *Server) DeploymentStatuses() []DeploymentStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeploymentStatus(nil), s.deploymentStatuses...)
+}
+
+func (s *Server) InstallationTokens() []InstallationToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]InstallationToken(nil), s.installationTokens...)
+}
+
+// failure returns the first injected failure matching the request, nil if none.
+func (s *Server) failure(method string, path string) *Failure {
+	for i, f := range s.failures {
+		if f.Method != method || !f.path.MatchString(path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Header: r.Header.Clone(), Body: body})
+
+	if f := s.failure(r.Method, path); f != nil {
+		for name, values := range f.Header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+		writeJSON(w, f.Status, map[string]string{"message": http.StatusText(f.Status), "documentation_url": f.DocumentationURL})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case dispatchesPath.MatchString(path):
+		dispatch := Dispatch{}
+		if !decode(w, body, &dispatch) {
+			return
+		}
+		s.dispatches = append(s.dispatches, dispatch)
+		w.WriteHeader(http.StatusNoContent)
+
+	case commitStatusesPath.MatchString(path):
+		status := CommitStatus{SHA: commitStatusesPath.FindStringSubmatch(path)[1]}
+		if !decode(w, body, &status) {
+			return
+		}
+		s.commitStatuses = append(s.commitStatuses, status)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"id": s.id(), "state": status.State, "context": status.Context})
+
+	case deploymentsPath.MatchString(path):
+		deployment := Deployment{}
+		if !decode(w, body, &deployment) {
+			return
+		}
+		deployment.ID = s.id()
+		s.deployments = append(s.deployments, deployment)
+		writeJSON(w, http.StatusCreated, deployment)
+
+	case deploymentStatusesPath.MatchString(path):
+		id, _ := strconv.ParseInt(deploymentStatusesPath.FindStringSubmatch(path)[1], 10, 64)
+		if !s.hasDeployment(id) {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		status := DeploymentStatus{DeploymentID: id}
+		if !decode(w, body, &status) {
+			return
+		}
+		s.deploymentStatuses = append(s.deploymentStatuses, status)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"id": s.id(), "state": status.State})
+
+	case installationTokenPath.MatchString(path):
+		id, _ := strconv.ParseInt(installationTokenPath.FindStringSubmatch(path)[1], 10, 64)
+		token := InstallationToken{InstallationID: id, Token: fmt.Sprintf("installation-token-%d", s.id())}
+		s.installationTokens = append(s.installationTokens, token)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"token":      token.Token,
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+func (s *Server) id() int64 {
+	id := s.nextID
+	s.nextID++
+	return id
+}
+
+func (s *Server) hasDeployment(id int64) bool {
+	for _, deployment := range s.deployments {
+		if deployment.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(w http.ResponseWriter, body []byte, v interface{}) bool {
+	err := json.Unmarshal(body, v)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("Problems parsing JSON: %s", err)})
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int) {
+	writeJSON(w, status, map[string]string{"message": http.StatusText(status)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package internal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func writeFile(t *testing.T, fPath string, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(fPath), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(fPath, []byte(content), 0644))
+}
+
+// tempDir returns a new temporary directory, removed when the test ends.
+// setenv sets the env variable for the duration of the test, unsets it if value is empty.
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	if value == "" {
+		require.NoError(t, os.Unsetenv(key))
+	} else {
+		require.NoError(t, os.Setenv(key, value))
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "infra")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// chdir makes dir the working directory until the test ends.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// testBuildUtils returns a local artifact store rooted at dir and the demo-service BuildUtils using it.
+func testBuildUtils(t *testing.T, dir string) (*internal.LocalArtifactStore, *internal.BuildUtils) {
+	store, err := internal.NewLocalArtifactStore(dir)
+	require.NoError(t, err)
+
+	bu, err := internal.NewBuildUtils(store, "demo-service")
+	require.NoError(t, err)
+	return store, bu
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLastChecksumRace is returned when the last checksum was updated by a build of a newer commit.
+var ErrLastChecksumRace = errors.New("last checksum was updated by a newer build")
+
+// ChecksumPointer is the content of "<service>/last-checksum", the last built checksum of a service.
+type ChecksumPointer struct {
+	Checksum  string `json:"checksum"`
+	CommitSHA string `json:"commitSHA"`
+	// Committer time of CommitSHA, orders builds racing to update the pointer.
+	CommitTime time.Time `json:"commitTime"`
+
+	// store version the pointer was read at
+	version string
+}
+
+// GetLastCodeChecksumPointer returns nil if the service was never built.
+func (bu *BuildUtils) GetLastCodeChecksumPointer() (*ChecksumPointer, error) {
+	data, version, err := bu.store.GetVersion(bu.lastCodeChecksumKey())
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, nil // service was never deployed
+		}
+		return nil, err
+	}
+
+	pointer := ChecksumPointer{}
+	err = json.Unmarshal(data, &pointer)
+	if err != nil {
+		// written before pointers carried an ordering key, a plain checksum
+		pointer = ChecksumPointer{Checksum: strings.TrimSpace(string(data))}
+	}
+	pointer.version = version
+	return &pointer, nil
+}
+
+func (bu *BuildUtils) GetLastCodeChecksum() (string, error) {
+	pointer, err := bu.GetLastCodeChecksumPointer()
+	if err != nil {
+		return "", err
+	}
+	if pointer == nil {
+		return "", nil
+	}
+	return pointer.Checksum, nil
+}
+
+// CompareAndSwapLastCodeChecksum updates the last checksum to next, only if it still is expected, as read by
+// GetLastCodeChecksumPointer (nil when there was no last checksum), or if next was committed after the current last checksum.
+// Returns ErrLastChecksumRace otherwise.
+func (bu *BuildUtils) CompareAndSwapLastCodeChecksum(expected *ChecksumPointer, next ChecksumPointer) error {
+	data, err := json.Marshal(next)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal checksum pointer")
+	}
+
+	version := ""
+	if expected != nil {
+		version = expected.version
+	}
+	for {
+		err = bu.store.PutIfVersion(bu.lastCodeChecksumKey(), data, version)
+		if !errors.Is(err, ErrArtifactConflict) {
+			return err
+		}
+
+		current, err := bu.GetLastCodeChecksumPointer()
+		if err != nil {
+			return err
+		}
+		switch {
+		case current == nil:
+			log.Print(fmt.Sprintf("last checksum deleted by someone else, writing %s", next.Checksum))
+			version = ""
+		case current.Checksum == next.Checksum:
+			log.Print(fmt.Sprintf("last checksum already updated to %s by another build", next.Checksum))
+			return nil
+		case current.CommitTime.Before(next.CommitTime):
+			log.Print(fmt.Sprintf(
+				"last checksum updated to %s by another build, overwriting it with a newer commit (%s < %s)",
+				current.Checksum, current.CommitTime, next.CommitTime,
+			))
+			version = current.version
+		default:
+			return errors.Wrap(ErrLastChecksumRace, fmt.Sprintf(
+				"%s (commit %s, %s) is not older than %s (commit %s, %s)",
+				current.Checksum, current.CommitSHA, current.CommitTime, next.Checksum, next.CommitSHA, next.CommitTime,
+			))
+		}
+	}
+}
+
+// CommitTime returns the committer time of the commit, read from the git repository of the working directory.
+func CommitTime(commitSHA string) (time.Time, error) {
+	if commitSHA == "" {
+		commitSHA = "HEAD"
+	}
+
+	out, err := exec.Command("git", "show", "-s", "--format=%cI", commitSHA).Output()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, fmt.Sprintf("failed to get %s commit time", commitSHA))
+	}
+
+	commitTime, err := time.Parse(time.RFC3339, strings.TrimSpace(string(out)))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, fmt.Sprintf("failed to parse %s commit time", commitSHA))
+	}
+	return commitTime.UTC(), nil
+}
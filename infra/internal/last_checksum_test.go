@@ -0,0 +1,67 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestCompareAndSwapLastCodeChecksum(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+
+	// plain checksums written by older versions are still read
+	require.NoError(t, store.Put("demo-service/last-checksum", []byte("legacy")))
+	legacy, err := bu.GetLastCodeChecksumPointer()
+	require.NoError(t, err)
+	require.Equal(t, "legacy", legacy.Checksum)
+
+	t0 := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	older := internal.ChecksumPointer{Checksum: "older", CommitSHA: "a", CommitTime: t0}
+	newer := internal.ChecksumPointer{Checksum: "newer", CommitSHA: "b", CommitTime: t0.Add(time.Minute)}
+
+	// both builds read the legacy pointer, the newer one finishes first
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(legacy, newer))
+	err = bu.CompareAndSwapLastCodeChecksum(legacy, older)
+	require.True(t, errors.Is(err, internal.ErrLastChecksumRace))
+
+	checksum, err := bu.GetLastCodeChecksum()
+	require.NoError(t, err)
+	require.Equal(t, "newer", checksum)
+
+	// both builds read the legacy pointer, the older one finishes first
+	require.NoError(t, store.Put("demo-service/last-checksum", []byte("legacy")))
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(legacy, older))
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(legacy, newer))
+
+	checksum, err = bu.GetLastCodeChecksum()
+	require.NoError(t, err)
+	require.Equal(t, "newer", checksum)
+}
+
+func TestCompareAndSwapLastCodeChecksumDeleted(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+
+	pointer := internal.ChecksumPointer{Checksum: "old", CommitSHA: "a", CommitTime: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(nil, pointer))
+	expected, err := bu.GetLastCodeChecksumPointer()
+	require.NoError(t, err)
+
+	// deleted while building
+	require.NoError(t, store.Delete("demo-service/last-checksum"))
+	next := internal.ChecksumPointer{Checksum: "next", CommitSHA: "b", CommitTime: pointer.CommitTime}
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(expected, next))
+
+	checksum, err := bu.GetLastCodeChecksum()
+	require.NoError(t, err)
+	require.Equal(t, "next", checksum)
+
+	// a pointer created while building is only overwritten by newer commits
+	require.NoError(t, store.Delete("demo-service/last-checksum"))
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(nil, next))
+	err = bu.CompareAndSwapLastCodeChecksum(nil, pointer)
+	require.True(t, errors.Is(err, internal.ErrLastChecksumRace))
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	deployLockKey          = "lock.json"
+	deployLockPollInterval = 10 * time.Second
+)
+
+// ErrDeployLocked is returned when the deploy lock of a service environment is held by someone else.
+var ErrDeployLocked = errors.New("deploy lock held")
+
+// DeployLock is a lease on deploying a service to an environment, stored in "<service>/deployments/<env>/lock.json".
+type DeployLock struct {
+	Service string `json:"service"`
+	Env     string `json:"env"`
+	// Random, identifies the holder.
+	ID string `json:"id"`
+	// Who holds the lock, e.g. the GitHub Actions run.
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	// The lock can be taken over after it expires, e.g. when its holder was killed.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (l *DeployLock) String() string {
+	return fmt.Sprintf("%s @ %s held by %s since %s, expires at %s",
+		l.Service, l.Env, l.Owner, l.AcquiredAt.Format(time.RFC3339), l.ExpiresAt.Format(time.RFC3339),
+	)
+}
+
+func (l *DeployLock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+type LockOptions struct {
+	Owner string
+	// How long the lock is held at most, must be longer than the deploy.
+	TTL time.Duration
+	// How long to wait for a lock held by someone else, fail fast if 0.
+	Wait time.Duration
+}
+
+// GetDeployLock returns nil if nobody holds the lock.
+func (bu *BuildUtils) GetDeployLock(env string) (*DeployLock, error) {
+	lock, _, err := bu.getDeployLock(env)
+	return lock, err
+}
+
+// getDeployLock also returns the store version of the lock, empty if nobody holds it.
+func (bu *BuildUtils) getDeployLock(env string) (*DeployLock, string, error) {
+	data, version, err := bu.store.GetVersion(bu.deploymentKey(env, deployLockKey))
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	lock := DeployLock{}
+	err = json.Unmarshal(data, &lock)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to unmarshal deploy lock")
+	}
+	return &lock, version, nil
+}
+
+func newLockID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+// AcquireDeployLock takes the deploy lock of env, waiting up to opts.Wait if someone else holds it.
+// Returns ErrDeployLocked if the lock could not be taken in time.
+func (bu *BuildUtils) AcquireDeployLock(ctx context.Context, env string, opts LockOptions) (*DeployLock, error) {
+	if opts.TTL <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid deploy lock ttl: %s", opts.TTL))
+	}
+
+	id, err := newLockID()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(opts.Wait)
+	for {
+		held, version, err := bu.getDeployLock(env)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		if held == nil || held.Expired(now) {
+			if held != nil {
+				log.Print(fmt.Sprintf("taking over expired deploy lock: %s", held))
+			}
+
+			lock := DeployLock{
+				Service:    bu.service,
+				Env:        env,
+				ID:         id,
+				Owner:      opts.Owner,
+				AcquiredAt: now.UTC(),
+				ExpiresAt:  now.Add(opts.TTL).UTC(),
+			}
+			data, err := json.MarshalIndent(lock, "", "  ")
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to marshal deploy lock")
+			}
+
+			// only written if nobody took or renewed the lock since it was read
+			err = bu.store.PutIfVersion(bu.deploymentKey(env, deployLockKey), data, version)
+			if err == nil {
+				log.Print(fmt.Sprintf("deploy lock acquired: %s", &lock))
+				return &lock, nil
+			}
+			if !errors.Is(err, ErrArtifactConflict) {
+				return nil, err
+			}
+			continue // someone else was faster, read who
+		}
+
+		if !now.Add(deployLockPollInterval).Before(deadline) {
+			return nil, errors.Wrap(ErrDeployLocked, held.String())
+		}
+		log.Print(fmt.Sprintf("waiting for deploy lock: %s", held))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(deployLockPollInterval):
+		}
+	}
+}
+
+// ReleaseDeployLock deletes the lock, unless someone else took it over meanwhile.
+func (bu *BuildUtils) ReleaseDeployLock(lock *DeployLock) error {
+	held, err := bu.GetDeployLock(lock.Env)
+	if err != nil {
+		return err
+	}
+	if held == nil || held.ID != lock.ID {
+		log.Print(fmt.Sprintf("deploy lock was taken over, not releasing it: %s", held))
+		return nil
+	}
+
+	err = bu.store.Delete(bu.deploymentKey(lock.Env, deployLockKey))
+	if err != nil {
+		return err
+	}
+	log.Print("deploy lock released")
+	return nil
+}
+
+// ForceReleaseDeployLock deletes the lock of env whoever holds it.
+func (bu *BuildUtils) ForceReleaseDeployLock(env string) error {
+	return bu.store.Delete(bu.deploymentKey(env, deployLockKey))
+}
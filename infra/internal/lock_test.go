@@ -0,0 +1,88 @@
+package internal_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestDeployLock(t *testing.T) {
+	_, bu := testBuildUtils(t, tempDir(t))
+
+	ctx := context.Background()
+	lock, err := bu.AcquireDeployLock(ctx, "prod", internal.LockOptions{Owner: "run 1", TTL: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, "run 1", lock.Owner)
+
+	_, err = bu.AcquireDeployLock(ctx, "prod", internal.LockOptions{Owner: "run 2", TTL: time.Hour})
+	require.True(t, errors.Is(err, internal.ErrDeployLocked))
+
+	// other environments are not locked
+	devLock, err := bu.AcquireDeployLock(ctx, "dev", internal.LockOptions{Owner: "run 2", TTL: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, bu.ReleaseDeployLock(devLock))
+
+	require.NoError(t, bu.ReleaseDeployLock(lock))
+	held, err := bu.GetDeployLock("prod")
+	require.NoError(t, err)
+	require.Nil(t, held)
+
+	// expired locks are taken over, and not released by their previous holder
+	expired, err := bu.AcquireDeployLock(ctx, "prod", internal.LockOptions{Owner: "run 3", TTL: time.Millisecond})
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	lock, err = bu.AcquireDeployLock(ctx, "prod", internal.LockOptions{Owner: "run 4", TTL: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, bu.ReleaseDeployLock(expired))
+	held, err = bu.GetDeployLock("prod")
+	require.NoError(t, err)
+	require.Equal(t, lock.ID, held.ID)
+}
+
+func TestDeployLockConcurrentAcquire(t *testing.T) {
+	store, _ := testBuildUtils(t, tempDir(t))
+
+	for round := 0; round < 10; round++ {
+		var wg sync.WaitGroup
+		locks := make(chan *internal.DeployLock, 8)
+		errs := make(chan error, cap(locks))
+		for i := 0; i < cap(locks); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				bu, err := internal.NewBuildUtils(store, "demo-service")
+				if err == nil {
+					var lock *internal.DeployLock
+					lock, err = bu.AcquireDeployLock(context.Background(), "prod", internal.LockOptions{Owner: fmt.Sprintf("run %d", i), TTL: time.Hour})
+					if err == nil {
+						locks <- lock
+						return
+					}
+				}
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(locks)
+		close(errs)
+
+		for err := range errs {
+			require.True(t, errors.Is(err, internal.ErrDeployLocked), err.Error())
+		}
+		require.Len(t, locks, 1, "exactly one deploy holds the lock")
+		lock := <-locks
+		bu, err := internal.NewBuildUtils(store, "demo-service")
+		require.NoError(t, err)
+		held, err := bu.GetDeployLock("prod")
+		require.NoError(t, err)
+		require.Equal(t, lock.ID, held.ID)
+		require.NoError(t, bu.ReleaseDeployLock(lock))
+	}
+}
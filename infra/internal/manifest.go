@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const distManifest = "manifest.json"
+
+// ArtifactManifest describes a dist zip, the build step uploads it next to the dist zip
+// ("<service>/<checksum>/manifest.json") and the deploy step verifies the downloaded dist zip against it.
+type ArtifactManifest struct {
+	Service      string    `json:"service"`
+	Checksum     string    `json:"checksum"`
+	CommitSHA    string    `json:"commitSHA"`
+	BuilderRunID string    `json:"builderRunID"`
+	BuiltAt      time.Time `json:"builtAt"`
+	// Hex encoded SHA-256 of the dist zip.
+	ZipSHA256 string `json:"zipSHA256"`
+	// Hex encoded SHA-256 of every file in the dist zip, by path.
+	Files map[string]string `json:"files"`
+}
+
+func (bu *BuildUtils) checksumManifestKey(checksum string) string {
+	return filepath.Join(bu.service, checksum, distManifest)
+}
+
+// zipFileDigests returns the hex encoded SHA-256 of every file in the zip, by path.
+func zipFileDigests(zipData []byte) (map[string]string, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, err
+	}
+
+	digests := map[string]string{}
+	for _, zipFile := range zipReader.File {
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+
+		err := func() error {
+			r, err := zipFile.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			hash := sha256.New()
+			_, err = io.Copy(hash, r)
+			if err != nil {
+				return err
+			}
+			digests[zipFile.Name] = fmt.Sprintf("%x", hash.Sum(nil))
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return digests, nil
+}
+
+func (bu *BuildUtils) NewArtifactManifest(zipData []byte, checksum string, commitSHA string, builderRunID string) (*ArtifactManifest, error) {
+	files, err := zipFileDigests(zipData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dist zip")
+	}
+
+	return &ArtifactManifest{
+		Service:      bu.service,
+		Checksum:     checksum,
+		CommitSHA:    commitSHA,
+		BuilderRunID: builderRunID,
+		BuiltAt:      time.Now().UTC(),
+		ZipSHA256:    DistZipDigest(zipData),
+		Files:        files,
+	}, nil
+}
+
+// Verify checks that zipData is the dist zip described by the manifest.
+func (m *ArtifactManifest) Verify(zipData []byte) error {
+	digest := DistZipDigest(zipData)
+	if digest != m.ZipSHA256 {
+		return errors.New(fmt.Sprintf("dist zip sha256 mismatch: expected %s, got %s", m.ZipSHA256, digest))
+	}
+
+	files, err := zipFileDigests(zipData)
+	if err != nil {
+		return errors.Wrap(err, "failed to read dist zip")
+	}
+
+	var mismatches []string
+	for name, expected := range m.Files {
+		actual, ok := files[name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("%s: missing", name))
+		case actual != expected:
+			mismatches = append(mismatches, fmt.Sprintf("%s: sha256 mismatch", name))
+		}
+	}
+	for name := range files {
+		if _, ok := m.Files[name]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: unexpected file", name))
+		}
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return errors.New(fmt.Sprintf("dist zip does not match manifest: %s", strings.Join(mismatches, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package internal_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestDownloadDistZipVerifiesManifest(t *testing.T) {
+	dir := tempDir(t)
+
+	writeFile(t, filepath.Join(dir, "demo-service", "serverless.yml"), "service: demo\n")
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "echo binary")
+
+	chdir(t, dir)
+
+	store, bu := testBuildUtils(t, filepath.Join(dir, ".infra-store"))
+
+	zData, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+
+	manifest, err := bu.NewArtifactManifest(zData, "c1", "ffac537e", "42")
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	require.NoError(t, bu.UploadDistZip(manifest, zData, nil))
+
+	artifact, err := bu.DownloadDistZip("c1", dir)
+	require.NoError(t, err)
+	require.Equal(t, "ffac537e", artifact.Manifest.CommitSHA)
+	downloaded, err := ioutil.ReadFile(artifact.Path)
+	require.NoError(t, err)
+	require.Equal(t, zData, downloaded)
+
+	// tampered dist zip
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "evil binary")
+	evilZData, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+	require.NoError(t, store.Put("demo-service/c1/dist.zip", evilZData))
+	_, err = bu.DownloadDistZip("c1", dir)
+	require.Error(t, err)
+
+	// no manifest
+	require.NoError(t, store.Put("demo-service/c2/dist.zip", zData))
+	_, err = bu.DownloadDistZip("c2", dir)
+	require.Error(t, err)
+}
+
+func TestArtifactSignature(t *testing.T) {
+	dir := tempDir(t)
+
+	writeFile(t, filepath.Join(dir, "demo-service", "serverless.yml"), "service: demo\n")
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "echo binary")
+
+	chdir(t, dir)
+
+	store, bu := testBuildUtils(t, filepath.Join(dir, ".infra-store"))
+
+	encodedSigningKey, encodedVerifyKey, err := internal.GenerateSigningKeys()
+	require.NoError(t, err)
+	signingKey, err := internal.ParseSigningKey(encodedSigningKey)
+	require.NoError(t, err)
+	verifyKey, err := internal.ParseVerifyKey(encodedVerifyKey)
+	require.NoError(t, err)
+	_, encodedOtherVerifyKey, err := internal.GenerateSigningKeys()
+	require.NoError(t, err)
+	otherVerifyKey, err := internal.ParseVerifyKey(encodedOtherVerifyKey)
+	require.NoError(t, err)
+
+	zData, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+
+	signed, err := bu.NewArtifactManifest(zData, "signed", "ffac537e", "42")
+	require.NoError(t, err)
+	require.NoError(t, bu.UploadDistZip(signed, zData, signingKey))
+
+	unsigned, err := bu.NewArtifactManifest(zData, "unsigned", "ffac537e", "42")
+	require.NoError(t, err)
+	require.NoError(t, bu.UploadDistZip(unsigned, zData, nil))
+
+	artifact, _, err := bu.FetchArtifact("signed")
+	require.NoError(t, err)
+	require.NoError(t, artifact.VerifySignature(verifyKey))
+	require.Error(t, artifact.VerifySignature(otherVerifyKey))
+
+	artifact, _, err = bu.FetchArtifact("unsigned")
+	require.NoError(t, err)
+	require.Error(t, artifact.VerifySignature(verifyKey))
+
+	// manifest edited after signing
+	manifestData, err := store.Get("demo-service/signed/manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, store.Put("demo-service/signed/manifest.json", append(manifestData, '\n')))
+	artifact, _, err = bu.FetchArtifact("signed")
+	require.NoError(t, err)
+	require.Error(t, artifact.VerifySignature(verifyKey))
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	deployedTemplateKey  = "template.json"
+	serverlessPackageDir = ".serverless"
+	serverlessTemplate   = "cloudformation-template-update-stack.json"
+)
+
+const (
+	TemplateChangeAdded    = "+"
+	TemplateChangeRemoved  = "-"
+	TemplateChangeModified = "~"
+)
+
+// TemplateChange is a difference between the deployed and the planned CloudFormation templates.
+type TemplateChange struct {
+	// One of TemplateChangeAdded, TemplateChangeRemoved or TemplateChangeModified.
+	Kind string
+	// Dot separated path of the changed value, e.g. "Resources.EchoLambdaFunction.Properties.MemorySize".
+	Path string
+}
+
+func (c TemplateChange) String() string {
+	return fmt.Sprintf("%s %s", c.Kind, c.Path)
+}
+
+// DeployPlan is what a deploy would change, without touching the live stack.
+type DeployPlan struct {
+	// CloudFormation template generated by `serverless package`.
+	Template []byte
+	// Whether a template was ever recorded for the environment, if not every resource is reported as added.
+	FirstDeployment bool
+	Changes         []TemplateChange
+}
+
+func (bu *BuildUtils) recordDeployedTemplate(env string, distPath string) error {
+	template, err := ioutil.ReadFile(filepath.Join(distPath, serverlessPackageDir, serverlessTemplate))
+	if err != nil {
+		return errors.Wrap(err, "failed to read deployed template")
+	}
+	return bu.store.Put(bu.deploymentKey(env, deployedTemplateKey), template)
+}
+
+// Plan runs `serverless package` on the dist zip unzipped in a temporary directory and diffs the generated
+// CloudFormation template against the template recorded by the last deploy to env.
+func (bu *BuildUtils) Plan(ctx context.Context, env string, distZipPath string) (*DeployPlan, error) {
+	distPath, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(distPath)
+
+	err = bu.unpackDistZip(distZipPath, distPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = runStreaming(ctx, distPath, "serverless", "package", "--stage", env, "--package", serverlessPackageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := DeployPlan{}
+	plan.Template, err = ioutil.ReadFile(filepath.Join(distPath, serverlessPackageDir, serverlessTemplate))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read packaged template")
+	}
+
+	deployed, err := bu.store.Get(bu.deploymentKey(env, deployedTemplateKey))
+	if err != nil {
+		if !errors.Is(err, ErrArtifactNotFound) {
+			return nil, err
+		}
+		log.Print(fmt.Sprintf("no template recorded for %s, first deployment", env))
+		plan.FirstDeployment = true
+		deployed = []byte("{}")
+	}
+
+	plan.Changes, err = DiffTemplates(deployed, plan.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// DiffTemplates returns the changes from one JSON CloudFormation template to another, sorted by path.
+func DiffTemplates(from []byte, to []byte) ([]TemplateChange, error) {
+	var fromValue, toValue interface{}
+	err := json.Unmarshal(from, &fromValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal deployed template")
+	}
+	err = json.Unmarshal(to, &toValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal planned template")
+	}
+
+	var changes []TemplateChange
+	diffValues("", fromValue, toValue, &changes)
+	return changes, nil
+}
+
+// diffValues appends the changes between two decoded JSON values, objects are compared key by key.
+func diffValues(path string, from interface{}, to interface{}, changes *[]TemplateChange) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if !fromIsMap || !toIsMap {
+		if !reflect.DeepEqual(from, to) {
+			*changes = append(*changes, TemplateChange{Kind: TemplateChangeModified, Path: path})
+		}
+		return
+	}
+
+	keySet := map[string]bool{}
+	for key := range fromMap {
+		keySet[key] = true
+	}
+	for key := range toMap {
+		keySet[key] = true
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = fmt.Sprintf("%s.%s", path, key)
+		}
+
+		fromValue, inFrom := fromMap[key]
+		toValue, inTo := toMap[key]
+		switch {
+		case !inFrom:
+			*changes = append(*changes, TemplateChange{Kind: TemplateChangeAdded, Path: keyPath})
+		case !inTo:
+			*changes = append(*changes, TemplateChange{Kind: TemplateChangeRemoved, Path: keyPath})
+		default:
+			diffValues(keyPath, fromValue, toValue, changes)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestDiffTemplates(t *testing.T) {
+	deployed := `{
+		"Resources": {
+			"EchoLambdaFunction": {"Type": "AWS::Lambda::Function", "Properties": {"MemorySize": 256, "Layers": ["a"]}},
+			"OldLogGroup": {"Type": "AWS::Logs::LogGroup"}
+		},
+		"Outputs": {"ServiceEndpoint": {"Value": "x"}}
+	}`
+	planned := `{
+		"Resources": {
+			"EchoLambdaFunction": {"Type": "AWS::Lambda::Function", "Properties": {"MemorySize": 512, "Layers": ["a", "b"]}},
+			"NewLogGroup": {"Type": "AWS::Logs::LogGroup"}
+		},
+		"Outputs": {"ServiceEndpoint": {"Value": "x"}}
+	}`
+
+	changes, err := internal.DiffTemplates([]byte(deployed), []byte(planned))
+	require.NoError(t, err)
+	require.Equal(t, []internal.TemplateChange{
+		{Kind: internal.TemplateChangeModified, Path: "Resources.EchoLambdaFunction.Properties.Layers"},
+		{Kind: internal.TemplateChangeModified, Path: "Resources.EchoLambdaFunction.Properties.MemorySize"},
+		{Kind: internal.TemplateChangeAdded, Path: "Resources.NewLogGroup"},
+		{Kind: internal.TemplateChangeRemoved, Path: "Resources.OldLogGroup"},
+	}, changes)
+
+	changes, err = internal.DiffTemplates([]byte(planned), []byte(planned))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// a value changing type is modified, not diffed key by key
+	changes, err = internal.DiffTemplates([]byte(`{"Outputs": "x"}`), []byte(`{"Outputs": {"Value": "x"}}`))
+	require.NoError(t, err)
+	require.Equal(t, []internal.TemplateChange{{Kind: internal.TemplateChangeModified, Path: "Outputs"}}, changes)
+
+	// nothing deployed yet
+	changes, err = internal.DiffTemplates([]byte("{}"), []byte(planned))
+	require.NoError(t, err)
+	require.Equal(t, []internal.TemplateChange{
+		{Kind: internal.TemplateChangeAdded, Path: "Outputs"},
+		{Kind: internal.TemplateChangeAdded, Path: "Resources"},
+	}, changes)
+
+	_, err = internal.DiffTemplates([]byte("{}"), []byte("{"))
+	require.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidatePromotion checks that `to` directly follows `from` in the promotion order.
+func ValidatePromotion(order []string, from string, to string) error {
+	for i, env := range order {
+		if env != from {
+			continue
+		}
+		if i+1 < len(order) && order[i+1] == to {
+			return nil
+		}
+		break
+	}
+	return errors.New(fmt.Sprintf(
+		"cannot promote from %s to %s, promotion order: %s", from, to, strings.Join(order, " -> "),
+	))
+}
+
+// ParsePromotionOrder parses comma separated environments, e.g. "dev, staging, prod".
+func ParsePromotionOrder(order string) []string {
+	var envs []string
+	for _, env := range strings.Split(order, ",") {
+		env = strings.TrimSpace(env)
+		if env != "" {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// ValidateDeployOrder checks that checksum is live in the environment preceding env in the promotion order,
+// artifacts only reach the later environments by promotion. Environments not in the order are not checked.
+func (bu *BuildUtils) ValidateDeployOrder(order []string, env string, checksum string) error {
+	for i, orderEnv := range order {
+		if orderEnv != env {
+			continue
+		}
+		if i == 0 {
+			return nil
+		}
+
+		previous, err := bu.GetDeployment(order[i-1])
+		if err != nil {
+			return err
+		}
+		if previous == nil || previous.Checksum != checksum {
+			return errors.New(fmt.Sprintf(
+				"cannot deploy %s checksum %s to %s, it is not live on %s, promotion order: %s",
+				bu.service, checksum, env, order[i-1], strings.Join(order, " -> "),
+			))
+		}
+		return nil
+	}
+	return nil
+}
+
+// PromotionSource returns the deployment live in `from`, the one to promote to `to`.
+// Its artifact must be deployable to `to`, see ArtifactDeployable.
+func (bu *BuildUtils) PromotionSource(order []string, from string, to string, config *SigningConfig) (*DeploymentRecord, error) {
+	err := ValidatePromotion(order, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := bu.GetDeployment(from)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, errors.New(fmt.Sprintf("%s is not deployed to %s, nothing to promote", bu.service, from))
+	}
+
+	deployable, err := bu.ArtifactDeployable(source.Checksum, to, config)
+	if err != nil {
+		return nil, err
+	}
+	if !deployable {
+		return nil, errors.New(fmt.Sprintf(
+			"%s checksum %s cannot be deployed to %s, its dist zip, manifest or signature is missing", bu.service, source.Checksum, to,
+		))
+	}
+
+	return source, nil
+}
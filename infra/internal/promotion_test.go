@@ -0,0 +1,78 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestParsePromotionOrder(t *testing.T) {
+	require.Equal(t, []string{"dev", "staging", "prod"}, internal.ParsePromotionOrder(" dev, staging ,prod,"))
+	require.Empty(t, internal.ParsePromotionOrder(""))
+}
+
+func TestValidatePromotion(t *testing.T) {
+	order := []string{"dev", "staging", "prod"}
+
+	require.NoError(t, internal.ValidatePromotion(order, "dev", "staging"))
+	require.NoError(t, internal.ValidatePromotion(order, "staging", "prod"))
+
+	require.Error(t, internal.ValidatePromotion(order, "dev", "prod"), "skipping staging")
+	require.Error(t, internal.ValidatePromotion(order, "staging", "dev"), "backwards")
+	require.Error(t, internal.ValidatePromotion(order, "prod", "prod"), "past the last environment")
+	require.Error(t, internal.ValidatePromotion(order, "qa", "staging"), "unknown environment")
+}
+
+func TestPromotionSource(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+	order := []string{"dev", "staging", "prod"}
+	config := &internal.SigningConfig{ProtectedEnvs: []string{"prod"}}
+
+	_, err := bu.PromotionSource(order, "dev", "staging", config)
+	require.Error(t, err, "nothing deployed to dev")
+
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c1", CommitSHA: "a"}))
+	_, err = bu.PromotionSource(order, "dev", "staging", config)
+	require.Error(t, err, "artifact of c1 is gone")
+
+	require.NoError(t, store.Put("demo-service/c1/dist.zip", []byte("zip")))
+	_, err = bu.PromotionSource(order, "dev", "staging", config)
+	require.Error(t, err, "manifest of c1 is gone")
+
+	require.NoError(t, store.Put("demo-service/c1/manifest.json", []byte("manifest")))
+	source, err := bu.PromotionSource(order, "dev", "staging", config)
+	require.NoError(t, err)
+	require.Equal(t, "c1", source.Checksum)
+	require.Equal(t, "a", source.CommitSHA)
+
+	_, err = bu.PromotionSource(order, "dev", "prod", config)
+	require.Error(t, err)
+
+	// prod is protected, only signed artifacts are promoted to it
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "staging", Checksum: "c1", CommitSHA: "a"}))
+	_, err = bu.PromotionSource(order, "staging", "prod", config)
+	require.Error(t, err)
+
+	require.NoError(t, store.Put("demo-service/c1/manifest.json.sig", []byte("signature")))
+	source, err = bu.PromotionSource(order, "staging", "prod", config)
+	require.NoError(t, err)
+	require.Equal(t, "c1", source.Checksum)
+}
+
+func TestValidateDeployOrder(t *testing.T) {
+	_, bu := testBuildUtils(t, tempDir(t))
+	order := []string{"dev", "staging", "prod"}
+
+	// anything can be deployed to the first environment, and to environments out of the order
+	require.NoError(t, bu.ValidateDeployOrder(order, "dev", "c1"))
+	require.NoError(t, bu.ValidateDeployOrder(order, "sandbox", "c1"))
+
+	require.Error(t, bu.ValidateDeployOrder(order, "staging", "c1"), "nothing live on dev")
+
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c1"}))
+	require.NoError(t, bu.ValidateDeployOrder(order, "staging", "c1"))
+	require.Error(t, bu.ValidateDeployOrder(order, "staging", "c2"))
+	require.Error(t, bu.ValidateDeployOrder(order, "prod", "c1"), "not live on staging")
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ArtifactDeployable reports whether the build artifact of checksum can be deployed to env:
+// its dist zip and manifest exist and, if env is protected, its manifest signature too.
+// The signature itself is verified by the deploy.
+func (bu *BuildUtils) ArtifactDeployable(checksum string, env string, config *SigningConfig) (bool, error) {
+	keys := []string{bu.checksumManifestKey(checksum), bu.checksumDistZipKey(checksum)}
+	if config.IsProtected(env) {
+		keys = append(keys, bu.checksumManifestSignatureKey(checksum))
+	}
+
+	for _, key := range keys {
+		exists, err := bu.store.Exists(key)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RollbackTarget returns the deployment to env `steps` deployments before the live one.
+// Deployments of the live checksum, of checksums rolled back from (see DeploymentRecord.RolledBackFrom), repeated checksums
+// and checksums no longer deployable are skipped, see ArtifactDeployable.
+func (bu *BuildUtils) RollbackTarget(env string, steps int, config *SigningConfig) (*DeploymentRecord, error) {
+	if steps < 1 {
+		return nil, errors.New(fmt.Sprintf("invalid steps: %d, must be at least 1", steps))
+	}
+
+	history, err := bu.GetDeploymentHistory(env)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) < 1 {
+		return nil, errors.New(fmt.Sprintf("%s was never deployed to %s", bu.service, env))
+	}
+
+	seen := map[string]bool{history[0].Checksum: true}
+	for _, record := range history {
+		if record.RolledBackFrom != "" {
+			seen[record.RolledBackFrom] = true
+		}
+	}
+	var candidates []DeploymentRecord
+	for _, record := range history[1:] {
+		if seen[record.Checksum] {
+			continue
+		}
+		seen[record.Checksum] = true
+
+		deployable, err := bu.ArtifactDeployable(record.Checksum, env, config)
+		if err != nil {
+			return nil, err
+		}
+		if !deployable {
+			continue
+		}
+
+		candidates = append(candidates, record)
+		if len(candidates) == steps {
+			return &record, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf(
+		"cannot rollback %s on %s by %d step(s): only %d earlier artifact(s) available",
+		bu.service, env, steps, len(candidates),
+	))
+}
@@ -0,0 +1,105 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+
+	config := &internal.SigningConfig{ProtectedEnvs: []string{"prod"}}
+	_, err := bu.RollbackTarget("prod", 1, config)
+	require.Error(t, err)
+
+	deployedAt := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, checksum := range []string{"c1", "c2", "c3", "c3", "c4"} {
+		require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{
+			Env:        "prod",
+			Checksum:   checksum,
+			DeployedAt: deployedAt.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	for _, checksum := range []string{"c1", "c3", "c4"} {
+		for _, name := range []string{"dist.zip", "manifest.json", "manifest.json.sig"} {
+			require.NoError(t, store.Put("demo-service/"+checksum+"/"+name, []byte("data")))
+		}
+	}
+
+	current, err := bu.GetDeployment("prod")
+	require.NoError(t, err)
+	require.Equal(t, "c4", current.Checksum)
+	require.Equal(t, "demo-service", current.Service)
+
+	target, err := bu.RollbackTarget("prod", 1, config)
+	require.NoError(t, err)
+	require.Equal(t, "c3", target.Checksum)
+
+	// c2 artifact is gone
+	target, err = bu.RollbackTarget("prod", 2, config)
+	require.NoError(t, err)
+	require.Equal(t, "c1", target.Checksum)
+
+	_, err = bu.RollbackTarget("prod", 3, config)
+	require.Error(t, err)
+
+	_, err = bu.RollbackTarget("dev", 1, config)
+	require.Error(t, err)
+
+	// c3 is left without a manifest by an interrupted gc
+	require.NoError(t, store.Delete("demo-service/c3/manifest.json"))
+	target, err = bu.RollbackTarget("prod", 1, config)
+	require.NoError(t, err)
+	require.Equal(t, "c1", target.Checksum)
+
+	// c1 is not signed, it cannot be deployed to prod any more
+	require.NoError(t, store.Delete("demo-service/c1/manifest.json.sig"))
+	_, err = bu.RollbackTarget("prod", 1, config)
+	require.Error(t, err)
+}
+
+func TestRollbackTargetTwice(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+	config := &internal.SigningConfig{}
+
+	deployedAt := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, checksum := range []string{"c1", "c2", "c3"} {
+		for _, name := range []string{"dist.zip", "manifest.json"} {
+			require.NoError(t, store.Put("demo-service/"+checksum+"/"+name, []byte("data")))
+		}
+		require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{
+			Env:        "prod",
+			Checksum:   checksum,
+			DeployedAt: deployedAt.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	// c3 is bad
+	target, err := bu.RollbackTarget("prod", 1, config)
+	require.NoError(t, err)
+	require.Equal(t, "c2", target.Checksum)
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{
+		Env:            "prod",
+		Checksum:       "c2",
+		DeployedAt:     deployedAt.Add(3 * time.Hour),
+		RolledBackFrom: "c3",
+	}))
+
+	// so is c2, c3 is not rolled back to
+	target, err = bu.RollbackTarget("prod", 1, config)
+	require.NoError(t, err)
+	require.Equal(t, "c1", target.Checksum)
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{
+		Env:            "prod",
+		Checksum:       "c1",
+		DeployedAt:     deployedAt.Add(4 * time.Hour),
+		RolledBackFrom: "c2",
+	}))
+
+	_, err = bu.RollbackTarget("prod", 1, config)
+	require.Error(t, err)
+}
@@ -6,11 +6,22 @@ import (
 )
 
 type Secrets struct {
-	// The bucket use to store deployment related state.
-	InfraBucket string `envconfig:"INFRA_AWS_S3_BUCKET" required:"true"`
+	// The bucket use to store deployment related state, required by the "s3" artifact store.
+	InfraBucket string `envconfig:"INFRA_AWS_S3_BUCKET" required:"false"`
 	// Github Personal Access Token
 	// (https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line)
-	PersonalAccessToken string `envconfig:"PERSONAL_ACCESS_TOKEN" required:"true"`
+	// Only used when no GitHub App is configured.
+	PersonalAccessToken string `envconfig:"PERSONAL_ACCESS_TOKEN" required:"false"`
+	// GitHub App the client authenticates as, through installation tokens
+	// (https://docs.github.com/en/developers/apps/authenticating-with-github-apps)
+	GitHubAppID             int64 `envconfig:"GITHUB_APP_ID" required:"false"`
+	GitHubAppInstallationID int64 `envconfig:"GITHUB_APP_INSTALLATION_ID" required:"false"`
+	// PEM private key of the GitHub App, optionally base64 encoded.
+	GitHubAppPrivateKey string `envconfig:"GITHUB_APP_PRIVATE_KEY" required:"false"`
+	// Base64 encoded ed25519 private key (or seed) the build step signs artifact manifests with, see `infra keygen`.
+	ArtifactSigningKey string `envconfig:"ARTIFACT_SIGNING_KEY" required:"false"`
+	// Base64 encoded ed25519 public key artifact manifest signatures are verified with.
+	ArtifactVerifyKey string `envconfig:"ARTIFACT_VERIFY_KEY" required:"false"`
 }
 
 func LoadSecrets() (*Secrets, error) {
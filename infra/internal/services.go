@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiscoverServices returns the paths, relative to root, of every directory under root containing a serverless.yml.
+// Hidden directories (e.g. .serverless, .bin), node_modules and service directories are not searched.
+// root itself is not a service, a serverless.yml in root is ignored.
+func DiscoverServices(root string) ([]string, error) {
+	var services []string
+	err := filepath.Walk(root, func(fPath string, fInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fInfo.IsDir() {
+			return nil
+		}
+
+		if fPath == root {
+			return nil
+		}
+		if strings.HasPrefix(fInfo.Name(), ".") || fInfo.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+
+		if _, err := os.Stat(filepath.Join(fPath, serverlessYML)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		service, err := filepath.Rel(root, fPath)
+		if err != nil {
+			return err
+		}
+		services = append(services, service)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(services)
+	return services, nil
+}
@@ -0,0 +1,39 @@
+package internal_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestDiscoverServices(t *testing.T) {
+	dir := tempDir(t)
+	for _, fPath := range []string{
+		"serverless.yml",
+		"zeta-service/serverless.yml",
+		"alpha-service/serverless.yml",
+		"alpha-service/nested/serverless.yml",
+		"alpha-service/.serverless/serverless.yml",
+		"group/beta-service/serverless.yml",
+		".hidden/serverless.yml",
+		"node_modules/some-package/serverless.yml",
+		"group/node_modules/serverless.yml",
+		"not-a-service/main.go",
+	} {
+		writeFile(t, filepath.Join(dir, fPath), "")
+	}
+
+	services, err := internal.DiscoverServices(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alpha-service", "group/beta-service", "zeta-service"}, services)
+
+	services, err = internal.DiscoverServices(filepath.Join(dir, "not-a-service"))
+	require.NoError(t, err)
+	require.Empty(t, services)
+
+	_, err = internal.DiscoverServices(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
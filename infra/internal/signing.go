@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+const distManifestSignature = "manifest.json.sig"
+
+type SigningConfig struct {
+	// Comma separated environments only artifacts signed by the build step can be deployed to.
+	ProtectedEnvs []string `envconfig:"PROTECTED_ENVS" default:"prod"`
+}
+
+func LoadSigningConfig() (*SigningConfig, error) {
+	config := SigningConfig{}
+	err := envconfig.Process("", &config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load signing config")
+	}
+	return &config, nil
+}
+
+func (c *SigningConfig) IsProtected(env string) bool {
+	for _, protectedEnv := range c.ProtectedEnvs {
+		if protectedEnv == env {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSigningKey decodes a base64 encoded ed25519 private key, or its 32 bytes seed.
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode signing key")
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("invalid signing key size: %d", len(key)))
+	}
+}
+
+// ParseVerifyKey decodes a base64 encoded ed25519 public key.
+func ParseVerifyKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode verify key")
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New(fmt.Sprintf("invalid verify key size: %d", len(key)))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (bu *BuildUtils) checksumManifestSignatureKey(checksum string) string {
+	return filepath.Join(bu.service, checksum, distManifestSignature)
+}
+
+func (bu *BuildUtils) getManifestSignature(checksum string) ([]byte, error) {
+	data, err := bu.store.Get(bu.checksumManifestSignatureKey(checksum))
+	if err != nil {
+		if errors.Is(err, ErrArtifactNotFound) {
+			return nil, nil // unsigned
+		}
+		return nil, err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode manifest signature")
+	}
+	return signature, nil
+}
+
+// VerifySignature checks the detached signature of the artifact manifest,
+// the manifest itself covers the dist zip.
+func (a *DistArtifact) VerifySignature(verifyKey ed25519.PublicKey) error {
+	if a.Signature == nil {
+		return errors.New(fmt.Sprintf("checksum %s is not signed", a.Checksum))
+	}
+	if !ed25519.Verify(verifyKey, a.ManifestData, a.Signature) {
+		return errors.New(fmt.Sprintf("checksum %s signature is invalid", a.Checksum))
+	}
+	return nil
+}
+
+// checkSignature refuses to deploy to protected environments artifacts not signed with the verify key.
+// Invalid signatures are refused everywhere.
+func (a *DistArtifact) checkSignature(env string, config *SigningConfig, verifyKey ed25519.PublicKey) error {
+	if !config.IsProtected(env) {
+		if a.Signature == nil || verifyKey == nil {
+			log.Print(fmt.Sprintf("%s is not protected, skipping signature verification", env))
+			return nil
+		}
+		return a.VerifySignature(verifyKey)
+	}
+
+	if verifyKey == nil {
+		return errors.New(fmt.Sprintf("%s is protected but no verify key is configured", env))
+	}
+	err := a.VerifySignature(verifyKey)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("refusing to deploy to protected env %s", env))
+	}
+	log.Print("signature verified")
+	return nil
+}
+
+// GenerateSigningKeys returns a new base64 encoded ed25519 key pair, see ParseSigningKey and ParseVerifyKey.
+func GenerateSigningKeys() (string, string, error) {
+	verifyKey, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(signingKey.Seed()), base64.StdEncoding.EncodeToString(verifyKey), nil
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DeploymentStatus is what is live for a service in an environment.
+type DeploymentStatus struct {
+	DeploymentRecord
+	// The last built checksum of the service.
+	LastChecksum string `json:"lastChecksum"`
+	// Whether the live checksum lags behind the last built one.
+	Behind bool `json:"behind"`
+}
+
+// DeploymentStatuses returns the live deployments found in store, sorted by service and env.
+// Empty service or env match any.
+func DeploymentStatuses(store ArtifactStore, service string, env string) ([]DeploymentStatus, error) {
+	prefix := ""
+	if service != "" {
+		prefix = path.Join(service, deploymentsDir) + "/"
+	}
+	infos, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []DeploymentStatus
+	buildUtils := map[string]*BuildUtils{}
+	for _, info := range infos {
+		// "<service>/deployments/<env>/current.json"
+		if path.Base(info.Key) != currentDeploymentKey {
+			continue
+		}
+		envDir := path.Dir(info.Key)
+		if path.Base(path.Dir(envDir)) != deploymentsDir {
+			continue
+		}
+		keyService := path.Dir(path.Dir(envDir))
+		keyEnv := path.Base(envDir)
+		if (service != "" && keyService != service) || (env != "" && keyEnv != env) {
+			continue
+		}
+
+		bu, ok := buildUtils[keyService]
+		if !ok {
+			bu, err = NewBuildUtils(store, keyService)
+			if err != nil {
+				return nil, err
+			}
+			buildUtils[keyService] = bu
+		}
+
+		record, err := bu.GetDeployment(keyEnv)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+
+		lastChecksum, err := bu.GetLastCodeChecksum()
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, DeploymentStatus{
+			DeploymentRecord: *record,
+			LastChecksum:     lastChecksum,
+			Behind:           lastChecksum != "" && lastChecksum != record.Checksum,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Service != statuses[j].Service {
+			return strings.Compare(statuses[i].Service, statuses[j].Service) < 0
+		}
+		return strings.Compare(statuses[i].Env, statuses[j].Env) < 0
+	})
+	return statuses, nil
+}
@@ -0,0 +1,55 @@
+package internal_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestDeploymentStatuses(t *testing.T) {
+	store, bu := testBuildUtils(t, tempDir(t))
+	other, err := internal.NewBuildUtils(store, "group/other-service")
+	require.NoError(t, err)
+
+	deployedAt := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, bu.CompareAndSwapLastCodeChecksum(nil, internal.ChecksumPointer{Checksum: "c2"}))
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "prod", Checksum: "c1", DeployedAt: deployedAt}))
+	require.NoError(t, bu.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "c2", DeployedAt: deployedAt}))
+	require.NoError(t, other.RecordDeployment(internal.DeploymentRecord{Env: "dev", Checksum: "o1", DeployedAt: deployedAt}))
+	// neither the lock nor the history is a deployment
+	_, err = bu.AcquireDeployLock(context.Background(), "staging", internal.LockOptions{TTL: time.Minute})
+	require.NoError(t, err)
+
+	// every service of the store, whether it is still in the repository or not
+	statuses, err := internal.DeploymentStatuses(store, "", "")
+	require.NoError(t, err)
+	var summaries []string
+	for _, status := range statuses {
+		summaries = append(summaries, fmt.Sprintf("%s %s %s behind=%t", status.Service, status.Env, status.Checksum, status.Behind))
+	}
+	require.Equal(t, []string{
+		"demo-service dev c2 behind=false",
+		"demo-service prod c1 behind=true",
+		"group/other-service dev o1 behind=false",
+	}, summaries)
+	require.Equal(t, "c2", statuses[1].LastChecksum)
+
+	statuses, err = internal.DeploymentStatuses(store, "", "prod")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, "c1", statuses[0].Checksum)
+
+	statuses, err = internal.DeploymentStatuses(store, "group/other-service", "")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, "o1", statuses[0].Checksum)
+
+	statuses, err = internal.DeploymentStatuses(store, "unknown-service", "")
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+}
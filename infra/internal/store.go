@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+const (
+	ArtifactStoreS3    = "s3"
+	ArtifactStoreLocal = "local"
+)
+
+// ErrArtifactNotFound is returned by an ArtifactStore when the requested key does not exist.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// ErrArtifactConflict is returned by ArtifactStore.PutIfVersion when the key changed since its version was read.
+var ErrArtifactConflict = errors.New("artifact changed concurrently")
+
+// ArtifactStore persists build artifacts and deployment state under "/" separated keys,
+// e.g. "demo-service/last-checksum".
+type ArtifactStore interface {
+	Get(key string) ([]byte, error)
+	// GetVersion is Get also returning the version of the data, an opaque value changed by every write of the key.
+	GetVersion(key string) ([]byte, string, error)
+	Put(key string, data []byte) error
+	// PutIfVersion writes the key only if it is still at version, an empty version meaning the key must not exist.
+	// Returns ErrArtifactConflict otherwise.
+	PutIfVersion(key string, data []byte, version string) error
+	Exists(key string) (bool, error)
+	List(prefix string) ([]ArtifactInfo, error)
+	Delete(key string) error
+}
+
+type ArtifactInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type ArtifactStoreConfig struct {
+	// The artifact store implementation, one of "s3" or "local".
+	ArtifactStore string `envconfig:"INFRA_ARTIFACT_STORE" default:"s3"`
+	// The root directory of the "local" artifact store.
+	ArtifactStoreDir string `envconfig:"INFRA_ARTIFACT_STORE_DIR" default:".infra-store"`
+}
+
+func LoadArtifactStoreConfig() (*ArtifactStoreConfig, error) {
+	config := ArtifactStoreConfig{}
+	err := envconfig.Process("", &config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load artifact store config")
+	}
+	return &config, nil
+}
+
+// NewArtifactStore returns the ArtifactStore selected by config.
+// The bucket is only used by the "s3" store.
+func NewArtifactStore(config *ArtifactStoreConfig, bucket string) (ArtifactStore, error) {
+	switch config.ArtifactStore {
+	case ArtifactStoreS3:
+		if bucket == "" {
+			return nil, errors.New("s3 artifact store requires a bucket")
+		}
+		return NewS3ArtifactStore(bucket)
+	case ArtifactStoreLocal:
+		return NewLocalArtifactStore(config.ArtifactStoreDir)
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown artifact store: %s", config.ArtifactStore))
+	}
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// prefix of the files written next to the keys, temporary files and mutexes, never listed
+	localTempPrefix   = ".tmp-"
+	localKeyLockStale = 10 * time.Second
+)
+
+// LocalArtifactStore is a directory backed ArtifactStore, each key is a file under the root directory.
+// Useful to run the build/deploy flow on a laptop or in tests, without AWS.
+type LocalArtifactStore struct {
+	root string
+}
+
+func NewLocalArtifactStore(root string) (*LocalArtifactStore, error) {
+	if root == "" {
+		return nil, errors.New("local artifact store requires a directory")
+	}
+
+	err := os.MkdirAll(root, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalArtifactStore{root: root}, nil
+}
+
+func (s *LocalArtifactStore) path(key string) (string, error) {
+	fPath := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(fPath, fmt.Sprintf("%s%s", filepath.Clean(s.root), string(os.PathSeparator))) {
+		return "", errors.New(fmt.Sprintf("%s: illegal key", key))
+	}
+	return fPath, nil
+}
+
+func (s *LocalArtifactStore) Get(key string) ([]byte, error) {
+	fPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(fPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrArtifactNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *LocalArtifactStore) GetVersion(key string) ([]byte, string, error) {
+	data, err := s.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, localVersion(data), nil
+}
+
+// localVersion is the version of the local store keys, the digest of their content.
+func localVersion(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func (s *LocalArtifactStore) Put(key string, data []byte) error {
+	fPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	return s.write(fPath, data)
+}
+
+// PutIfVersion compares and writes the key holding its mutex, see lockKey.
+func (s *LocalArtifactStore) PutIfVersion(key string, data []byte, version string) error {
+	fPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lockKey(fPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := ioutil.ReadFile(fPath)
+	switch {
+	case os.IsNotExist(err):
+		if version != "" {
+			return ErrArtifactConflict
+		}
+	case err != nil:
+		return err
+	case version == "" || localVersion(current) != version:
+		return ErrArtifactConflict
+	}
+	return s.write(fPath, data)
+}
+
+// lockKey takes the mutex of the key file, a file created with O_EXCL next to it.
+// Mutexes older than localKeyLockStale are left behind by killed processes and removed.
+func (s *LocalArtifactStore) lockKey(fPath string) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(fPath), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	lockPath := filepath.Join(filepath.Dir(fPath), fmt.Sprintf("%slock-%s", localTempPrefix, filepath.Base(fPath)))
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		fInfo, err := os.Stat(lockPath)
+		if err == nil && time.Since(fInfo.ModTime()) > localKeyLockStale {
+			os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *LocalArtifactStore) write(fPath string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(fPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// write + rename, readers never see a partially written file
+	f, err := ioutil.TempFile(filepath.Dir(fPath), localTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), fPath)
+}
+
+func (s *LocalArtifactStore) Exists(key string) (bool, error) {
+	fPath, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(fPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *LocalArtifactStore) List(prefix string) ([]ArtifactInfo, error) {
+	var infos []ArtifactInfo
+	err := filepath.Walk(s.root, func(fPath string, fInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fInfo.IsDir() || strings.HasPrefix(fInfo.Name(), localTempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, fPath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		infos = append(infos, ArtifactInfo{Key: key, Size: fInfo.Size(), LastModified: fInfo.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *LocalArtifactStore) Delete(key string) error {
+	fPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(fPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestLocalArtifactStore(t *testing.T) {
+	store, err := internal.NewLocalArtifactStore(tempDir(t))
+	require.NoError(t, err)
+
+	_, err = store.Get("demo-service/last-checksum")
+	require.Equal(t, internal.ErrArtifactNotFound, err)
+
+	exists, err := store.Exists("demo-service/last-checksum")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, store.Put("demo-service/last-checksum", []byte("abc")))
+	require.NoError(t, store.Put("demo-service/abc/dist.zip", []byte("zip")))
+	require.NoError(t, store.Put("other-service/last-checksum", []byte("def")))
+
+	data, err := store.Get("demo-service/last-checksum")
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(data))
+
+	exists, err = store.Exists("demo-service/last-checksum")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	infos, err := store.List("demo-service/")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, "demo-service/abc/dist.zip", infos[0].Key)
+	require.Equal(t, int64(3), infos[0].Size)
+	require.Equal(t, "demo-service/last-checksum", infos[1].Key)
+
+	require.NoError(t, store.Delete("demo-service/abc/dist.zip"))
+	require.NoError(t, store.Delete("demo-service/abc/dist.zip"))
+	infos, err = store.List("demo-service/")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	_, err = store.Get("../escape")
+	require.Error(t, err)
+}
+
+func TestLocalArtifactStorePutIfVersion(t *testing.T) {
+	store, err := internal.NewLocalArtifactStore(tempDir(t))
+	require.NoError(t, err)
+	key := "demo-service/deployments/prod/lock.json"
+
+	// created only if absent
+	require.NoError(t, store.PutIfVersion(key, []byte("a"), ""))
+	require.True(t, errors.Is(store.PutIfVersion(key, []byte("b"), ""), internal.ErrArtifactConflict))
+
+	// replaced only if unchanged since read
+	data, version, err := store.GetVersion(key)
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+	require.NoError(t, store.Put(key, []byte("c")))
+	require.True(t, errors.Is(store.PutIfVersion(key, []byte("b"), version), internal.ErrArtifactConflict))
+
+	_, version, err = store.GetVersion(key)
+	require.NoError(t, err)
+	require.NoError(t, store.PutIfVersion(key, []byte("b"), version))
+	data, err = store.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, "b", string(data))
+
+	// deleted meanwhile
+	require.NoError(t, store.Delete(key))
+	require.True(t, errors.Is(store.PutIfVersion(key, []byte("d"), version), internal.ErrArtifactConflict))
+
+	// mutexes are not listed
+	infos, err := store.List("")
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
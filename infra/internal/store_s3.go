@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	aws_session "github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+type S3ArtifactStore struct {
+	bucket string
+
+	session *aws_session.Session
+	client  *s3.S3
+}
+
+func NewS3ArtifactStore(bucket string) (*S3ArtifactStore, error) {
+	session, err := aws_session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3ArtifactStore{bucket: bucket, session: session, client: s3.New(session)}, nil
+}
+
+func isS3NotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+func (s *S3ArtifactStore) Get(key string) ([]byte, error) {
+	buf := aws.NewWriteAtBuffer([]byte{})
+	downloader := s3manager.NewDownloader(s.session)
+	_, err := downloader.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrArtifactNotFound
+		}
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *S3ArtifactStore) GetVersion(key string) ([]byte, string, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, "", ErrArtifactNotFound
+		}
+		return nil, "", err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, aws.StringValue(out.ETag), nil
+}
+
+// PutIfVersion is a conditional PutObject, versions are ETags.
+// The SDK has no PutObjectInput field for the If-None-Match / If-Match headers, they are set on the request.
+func (s *S3ArtifactStore) PutIfVersion(key string, data []byte, version string) error {
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if version == "" {
+		req.HTTPRequest.Header.Set("If-None-Match", "*")
+	} else {
+		req.HTTPRequest.Header.Set("If-Match", version)
+	}
+
+	err := req.Send()
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			switch reqErr.StatusCode() {
+			// the key was deleted, written concurrently, or does not match
+			case http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed:
+				return ErrArtifactConflict
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3ArtifactStore) Put(key string, data []byte) error {
+	uploader := s3manager.NewUploader(s.session)
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *S3ArtifactStore) Exists(key string) (bool, error) {
+	_, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *S3ArtifactStore) List(prefix string) ([]ArtifactInfo, error) {
+	var infos []ArtifactInfo
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			infos = append(infos, ArtifactInfo{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+func (s *S3ArtifactStore) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"strings"
+
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
 )
@@ -8,6 +10,8 @@ import (
 type UserEnv struct {
 	// "owner/repo"
 	Repository string `envconfig:"REPOSITORY" required:"true"`
+	// Comma separated environments, artifacts can only be promoted from one environment to the next.
+	PromotionOrder []string `envconfig:"PROMOTION_ORDER" default:"dev,staging,prod"`
 }
 
 func LoadUserEnv() (*UserEnv, error) {
@@ -16,5 +20,6 @@ func LoadUserEnv() (*UserEnv, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load user env")
 	}
+	env.PromotionOrder = ParsePromotionOrder(strings.Join(env.PromotionOrder, ","))
 	return &env, nil
 }
@@ -7,26 +7,84 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
+// zipEpoch is the modification time of every zipped file, the earliest time the zip format can represent.
+var zipEpoch = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	zipRegularFileMode    os.FileMode = 0644
+	zipExecutableFileMode os.FileMode = 0755
+)
+
+// zipFileModes is the allow-list of file permissions carried through archives, see zipFileMode and unzipFileMode.
+var zipFileModes = map[os.FileMode]bool{
+	zipRegularFileMode:    true,
+	zipExecutableFileMode: true,
+}
+
+// zipFileMode normalizes a file permissions: 0755 if executable by anyone, 0644 (writable by the owner, readable by anyone) otherwise.
+func zipFileMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return zipExecutableFileMode
+	}
+	return zipRegularFileMode
+}
+
+// unzipFileMode returns the permissions of an unzipped file, archived modes not allowed are ignored,
+// including allowed permissions with setuid, setgid or sticky bits.
+func unzipFileMode(mode os.FileMode) os.FileMode {
+	if zipFileModes[mode] {
+		return mode
+	}
+	return zipRegularFileMode
+}
+
+// zipFiles zips the files deterministically, identical files produce byte identical archives:
+// entries are sorted by name, modification times are fixed and permissions normalized (see zipFileMode).
 func zipFiles(fPaths []string, fPathFunc func(string) (string, error)) ([]byte, error) {
+	zPaths := map[string]string{}
+	zNames := make([]string, 0, len(fPaths))
+	for _, fPath := range fPaths {
+		zPath, err := fPathFunc(fPath)
+		if err != nil {
+			return nil, err
+		}
+		zPath = filepath.ToSlash(zPath)
+		if _, ok := zPaths[zPath]; ok {
+			return nil, fmt.Errorf("%s: duplicated zip path", zPath)
+		}
+		zPaths[zPath] = fPath
+		zNames = append(zNames, zPath)
+	}
+	sort.Strings(zNames)
+
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
 
-	for _, fPath := range fPaths {
+	for _, zPath := range zNames {
 		if err := func() error {
-			r, err := os.Open(fPath)
+			r, err := os.Open(zPaths[zPath])
 			if err != nil {
 				return err
 			}
 			defer r.Close()
 
-			zPath, err := fPathFunc(fPath)
+			fInfo, err := r.Stat()
 			if err != nil {
 				return err
 			}
-			w, err := zipWriter.Create(zPath)
+
+			header := &zip.FileHeader{
+				Name:     zPath,
+				Method:   zip.Deflate,
+				Modified: zipEpoch,
+			}
+			header.SetMode(zipFileMode(fInfo.Mode()))
+			w, err := zipWriter.CreateHeader(header)
 			if err != nil {
 				return err
 			}
@@ -80,12 +138,19 @@ func unzipFiles(src string, dest string) error {
 				return err
 			}
 
-			f, err := os.Create(fPath)
+			mode := unzipFileMode(zipFile.Mode())
+			f, err := os.OpenFile(fPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 			if err != nil {
 				return err
 			}
 			defer f.Close()
 
+			// the umask may have restricted the permissions
+			err = f.Chmod(mode)
+			if err != nil {
+				return err
+			}
+
 			zipFileReader, err := zipFile.Open()
 			if err != nil {
 				return err
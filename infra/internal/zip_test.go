@@ -0,0 +1,117 @@
+package internal_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"infra/internal"
+)
+
+func TestGenerateDistZipIsReproducible(t *testing.T) {
+	dir := tempDir(t)
+
+	writeFile(t, filepath.Join(dir, "demo-service", "serverless.yml"), "service: demo\n")
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "echo binary")
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "auth"), "auth binary")
+
+	chdir(t, dir)
+
+	bu, err := internal.NewBuildUtils(nil, "demo-service")
+	require.NoError(t, err)
+
+	zData, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "demo-service", ".bin", "echo"), later, later))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "demo-service", "serverless.yml"), 0600))
+
+	zDataAgain, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+	require.Equal(t, zData, zDataAgain)
+	require.Equal(t, internal.DistZipDigest(zData), internal.DistZipDigest(zDataAgain))
+
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "new echo binary")
+	zDataChanged, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+	require.NotEqual(t, internal.DistZipDigest(zData), internal.DistZipDigest(zDataChanged))
+}
+
+func TestGenerateDistZipKeepsExecutablePermissions(t *testing.T) {
+	dir := tempDir(t)
+
+	writeFile(t, filepath.Join(dir, "demo-service", "serverless.yml"), "service: demo\n")
+	writeFile(t, filepath.Join(dir, "demo-service", ".bin", "echo"), "echo binary")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "demo-service", ".bin", "echo"), 0700))
+
+	chdir(t, dir)
+
+	bu, err := internal.NewBuildUtils(nil, "demo-service")
+	require.NoError(t, err)
+
+	zData, err := bu.GenerateDistZip()
+	require.NoError(t, err)
+
+	zipReader, err := zip.NewReader(bytes.NewReader(zData), int64(len(zData)))
+	require.NoError(t, err)
+
+	modes := map[string]os.FileMode{}
+	for _, zipFile := range zipReader.File {
+		modes[zipFile.Name] = zipFile.Mode()
+	}
+	require.Equal(t, map[string]os.FileMode{
+		".bin/echo":      0755,
+		"serverless.yml": 0644,
+	}, modes)
+}
+
+func TestUnzipFilesRestoresPermissions(t *testing.T) {
+	dir := tempDir(t)
+	writeFile(t, filepath.Join(dir, "src", "binary"), "binary")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "src", "binary"), 0700))
+	writeFile(t, filepath.Join(dir, "src", "config"), "config")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "src", "config"), 0600))
+
+	zData, err := internal.ZipFiles(
+		[]string{filepath.Join(dir, "src", "binary"), filepath.Join(dir, "src", "config")},
+		func(fPath string) (string, error) { return filepath.Rel(filepath.Join(dir, "src"), fPath) },
+	)
+	require.NoError(t, err)
+
+	// archived permissions outside the allow-list
+	zipReader, err := zip.NewReader(bytes.NewReader(zData), int64(len(zData)))
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	for _, zipFile := range zipReader.File {
+		require.NoError(t, zipWriter.Copy(zipFile))
+	}
+	header := &zip.FileHeader{Name: "setuid", Method: zip.Deflate}
+	header.SetMode(os.ModeSetuid | 0755)
+	w, err := zipWriter.CreateHeader(header)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("setuid"))
+	require.NoError(t, err)
+	require.NoError(t, zipWriter.Close())
+	zipPath := filepath.Join(dir, "dist.zip")
+	require.NoError(t, ioutil.WriteFile(zipPath, buf.Bytes(), 0644))
+
+	// even under a restrictive umask
+	umask := syscall.Umask(0077)
+	defer syscall.Umask(umask)
+	require.NoError(t, internal.UnzipFiles(zipPath, filepath.Join(dir, "dist")))
+
+	for name, mode := range map[string]os.FileMode{"binary": 0755, "config": 0644, "setuid": 0644} {
+		fInfo, err := os.Stat(filepath.Join(dir, "dist", name))
+		require.NoError(t, err)
+		require.Equal(t, mode, fInfo.Mode(), name)
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"infra/internal/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:]))
+}